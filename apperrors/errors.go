@@ -0,0 +1,131 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code is a stable, machine readable identifier for a class of failure.
+// Codes are part of the public API and must not be renamed.
+type Code string
+
+const (
+	CodeNotFound           Code = "not_found"
+	CodeConflict           Code = "conflict"
+	CodePreconditionFailed Code = "precondition_failed"
+	CodeValidation         Code = "validation_failed"
+	CodeUnauthenticated    Code = "unauthenticated"
+	CodeForbidden          Code = "forbidden"
+	CodeRateLimited        Code = "rate_limited"
+	CodeUnavailable        Code = "unavailable"
+	CodeInternal           Code = "internal"
+)
+
+// Error is a domain error carrying a Code, a client safe message and an optional cause
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+	Fields  []FieldError
+
+	sentinel bool
+}
+
+// FieldError describes why a single request field failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Sentinels for matching with errors.Is, e.g. errors.Is(err, apperrors.ErrNotFound)
+var (
+	ErrNotFound           = &Error{Code: CodeNotFound, Message: "resource not found", sentinel: true}
+	ErrConflict           = &Error{Code: CodeConflict, Message: "resource conflict", sentinel: true}
+	ErrPreconditionFailed = &Error{Code: CodePreconditionFailed, Message: "precondition failed", sentinel: true}
+	ErrValidation         = &Error{Code: CodeValidation, Message: "validation failed", sentinel: true}
+	ErrUnauthenticated    = &Error{Code: CodeUnauthenticated, Message: "authentication required", sentinel: true}
+	ErrForbidden          = &Error{Code: CodeForbidden, Message: "permission denied", sentinel: true}
+	ErrRateLimited        = &Error{Code: CodeRateLimited, Message: "rate limit exceeded", sentinel: true}
+	ErrUnavailable        = &Error{Code: CodeUnavailable, Message: "service unavailable", sentinel: true}
+)
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches any error against the sentinel of the same Code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.sentinel && t.Code == e.Code
+}
+
+// New creates an error with the given code and message
+func New(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap attaches a code and client safe message to an underlying error
+func Wrap(code Code, err error, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// NotFound creates a CodeNotFound error
+func NotFound(format string, args ...interface{}) *Error {
+	return New(CodeNotFound, format, args...)
+}
+
+// Conflict creates a CodeConflict error
+func Conflict(format string, args ...interface{}) *Error {
+	return New(CodeConflict, format, args...)
+}
+
+// PreconditionFailed creates a CodePreconditionFailed error
+func PreconditionFailed(format string, args ...interface{}) *Error {
+	return New(CodePreconditionFailed, format, args...)
+}
+
+// Validation creates a CodeValidation error
+func Validation(format string, args ...interface{}) *Error {
+	return New(CodeValidation, format, args...)
+}
+
+// InvalidFields creates a CodeValidation error listing every offending field
+func InvalidFields(fields ...FieldError) *Error {
+	return &Error{Code: CodeValidation, Message: "request validation failed", Fields: fields}
+}
+
+// Unauthenticated creates a CodeUnauthenticated error
+func Unauthenticated(format string, args ...interface{}) *Error {
+	return New(CodeUnauthenticated, format, args...)
+}
+
+// Forbidden creates a CodeForbidden error
+func Forbidden(format string, args ...interface{}) *Error {
+	return New(CodeForbidden, format, args...)
+}
+
+// RateLimited creates a CodeRateLimited error
+func RateLimited(format string, args ...interface{}) *Error {
+	return New(CodeRateLimited, format, args...)
+}
+
+// Unavailable creates a CodeUnavailable error wrapping the cause
+func Unavailable(err error, format string, args ...interface{}) *Error {
+	return Wrap(CodeUnavailable, err, format, args...)
+}
+
+// CodeOf returns the Code of the first Error in err's chain, or CodeInternal
+func CodeOf(err error) Code {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return CodeInternal
+}
@@ -1,8 +1,18 @@
 package cache
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
+// Cache is a key-value store for serialized values. A missing key is reported
+// as a nil value with a nil error, and a ttl of zero means the key never expires.
 type Cache interface {
 	Get(ctx context.Context, key string) (interface{}, error)
 	Set(ctx context.Context, key string, value interface{}) error
+	SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	GetMulti(ctx context.Context, keys []string) (map[string]interface{}, error)
+	SetMulti(ctx context.Context, values map[string]interface{}, ttl time.Duration) error
+	DeleteByPrefix(ctx context.Context, prefix string) error
 }
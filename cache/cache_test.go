@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheImplementations(t *testing.T) {
+	implementations := map[string]func() Cache{
+		"lru":  func() Cache { return NewLRUCache() },
+		"mock": func() Cache { return &RedisMock{} },
+	}
+
+	for name, newCache := range implementations {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			t.Run("get missing key", func(t *testing.T) {
+				c := newCache()
+				value, err := c.Get(ctx, "missing")
+				assert.NoError(t, err)
+				assert.Nil(t, value)
+			})
+
+			t.Run("ttl expiry", func(t *testing.T) {
+				c := newCache()
+				assert.NoError(t, c.SetWithTTL(ctx, "short", "v", 10*time.Millisecond))
+				assert.NoError(t, c.Set(ctx, "forever", "v"))
+
+				time.Sleep(20 * time.Millisecond)
+
+				value, _ := c.Get(ctx, "short")
+				assert.Nil(t, value)
+				value, _ = c.Get(ctx, "forever")
+				assert.Equal(t, "v", value)
+			})
+
+			t.Run("delete", func(t *testing.T) {
+				c := newCache()
+				assert.NoError(t, c.Set(ctx, "a", "1"))
+				assert.NoError(t, c.Set(ctx, "b", "2"))
+				assert.NoError(t, c.Delete(ctx, "a", "b", "missing"))
+
+				values, err := c.GetMulti(ctx, []string{"a", "b"})
+				assert.NoError(t, err)
+				assert.Empty(t, values)
+			})
+
+			t.Run("multi", func(t *testing.T) {
+				c := newCache()
+				assert.NoError(t, c.SetMulti(ctx, map[string]interface{}{"a": "1", "b": "2"}, time.Minute))
+
+				values, err := c.GetMulti(ctx, []string{"a", "b", "c"})
+				assert.NoError(t, err)
+				assert.Equal(t, map[string]interface{}{"a": "1", "b": "2"}, values)
+			})
+
+			t.Run("delete by prefix", func(t *testing.T) {
+				c := newCache()
+				assert.NoError(t, c.Set(ctx, "todos:list:1", "x"))
+				assert.NoError(t, c.Set(ctx, "todos:list:2", "y"))
+				assert.NoError(t, c.Set(ctx, "todos:1", "z"))
+				assert.NoError(t, c.DeleteByPrefix(ctx, "todos:list:"))
+
+				values, err := c.GetMulti(ctx, []string{"todos:list:1", "todos:list:2", "todos:1"})
+				assert.NoError(t, err)
+				assert.Equal(t, map[string]interface{}{"todos:1": "z"}, values)
+			})
+		})
+	}
+}
+
+func TestLRUCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUCache()
+
+	for i := 0; i <= c.capacity; i++ {
+		assert.NoError(t, c.Set(ctx, fmt.Sprintf("key-%d", i), i))
+	}
+
+	// The first key is the least recently used and must have been evicted
+	value, _ := c.Get(ctx, "key-0")
+	assert.Nil(t, value)
+	assert.Len(t, c.store, c.capacity)
+}
@@ -3,7 +3,9 @@ package cache
 import (
 	"container/list"
 	"context"
+	"strings"
 	"sync"
+	"time"
 )
 
 // LRUCache is an in-memory LRU cache implementation
@@ -20,8 +22,14 @@ type LRUCache struct {
 
 // entry is a key-value pair for the LRU cache
 type entry struct {
-	key   string
-	value interface{}
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// expired reports whether the entry has a TTL that has elapsed
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
 }
 
 // NewLRUCache creates a new LRUCache with the specified capacity
@@ -38,36 +46,122 @@ func (c *LRUCache) Get(ctx context.Context, key string) (interface{}, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if elem, ok := c.store[key]; ok {
-		c.ll.MoveToFront(elem) // Move accessed item to the front
-		return elem.Value.(*entry).value, nil
-	}
-	return nil, nil
+	return c.get(key, time.Now()), nil
 }
 
-// Set stores a value in the LRU cache
+// Set stores a value in the LRU cache without expiration
 func (c *LRUCache) Set(ctx context.Context, key string, value interface{}) error {
+	return c.SetWithTTL(ctx, key, value, 0)
+}
+
+// SetWithTTL stores a value in the LRU cache that expires after ttl
+func (c *LRUCache) SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value, ttl)
+	return nil
+}
+
+// Delete removes the given keys from the LRU cache
+func (c *LRUCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := c.store[key]; ok {
+			c.remove(elem)
+		}
+	}
+	return nil
+}
+
+// GetMulti retrieves the values present in the LRU cache for the given keys
+func (c *LRUCache) GetMulti(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	values := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		if value := c.get(key, now); value != nil {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// SetMulti stores several values in the LRU cache with the same ttl
+func (c *LRUCache) SetMulti(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	for key, value := range values {
+		c.set(key, value, ttl)
+	}
+	return nil
+}
+
+// DeleteByPrefix removes every key starting with prefix from the LRU cache
+func (c *LRUCache) DeleteByPrefix(ctx context.Context, prefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, elem := range c.store {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(elem)
+		}
+	}
+	return nil
+}
+
+// get looks up a key, dropping it if it has expired. The caller must hold c.mu.
+func (c *LRUCache) get(key string, now time.Time) interface{} {
+	elem, ok := c.store[key]
+	if !ok {
+		return nil
+	}
+
+	e := elem.Value.(*entry)
+	if e.expired(now) {
+		c.remove(elem)
+		return nil
+	}
+
+	c.ll.MoveToFront(elem) // Move accessed item to the front
+	return e.value
+}
+
+// set inserts or replaces a key. The caller must hold c.mu.
+func (c *LRUCache) set(key string, value interface{}, ttl time.Duration) {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
 	if elem, ok := c.store[key]; ok {
 		c.ll.MoveToFront(elem) // Move existing item to the front
-		elem.Value.(*entry).value = value
-		return nil
+		e := elem.Value.(*entry)
+		e.value = value
+		e.expiresAt = expiresAt
+		return
 	}
 
 	// If cache is at capacity, remove the least recently used item
 	if c.ll.Len() == c.capacity {
-		backElem := c.ll.Back()
-		if backElem != nil {
-			c.ll.Remove(backElem)
-			delete(c.store, backElem.Value.(*entry).key)
+		if backElem := c.ll.Back(); backElem != nil {
+			c.remove(backElem)
 		}
 	}
 
 	// Add new item to the front of the list
-	newEntry := &entry{key: key, value: value}
+	newEntry := &entry{key: key, value: value, expiresAt: expiresAt}
 	newElem := c.ll.PushFront(newEntry)
 	c.store[key] = newElem
-	return nil
+}
+
+// remove unlinks an element from both the list and the index. The caller must hold c.mu.
+func (c *LRUCache) remove(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.store, elem.Value.(*entry).key)
 }
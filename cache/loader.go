@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+)
+
+// Loader reads values of type T through a Cache, loading and storing them on
+// a miss. Concurrent misses of a key share one load, lookups that fail with
+// apperrors.ErrNotFound are remembered for NegativeTTL, and TTLs are
+// jittered. A key is refreshed before it expires with a probability that
+// rises as the expiry nears and with how long loading it took (XFetch), so
+// hot keys rarely expire at all. Keys must be dropped through Invalidate,
+// which deletes them again once loads that read the old value are done.
+type Loader[T any] struct {
+	Cache Cache
+	TTL   time.Duration
+	// NegativeTTL is how long a not found result is cached, 0 disables it
+	NegativeTTL time.Duration
+	// Jitter varies each TTL by up to this fraction either way
+	Jitter float64
+	// Beta scales early refreshes, 0 disables them
+	Beta float64
+	// Timeout bounds a load, 5s when 0. A load is shared by every caller
+	// missing the key, so it runs detached from their cancellation.
+	Timeout time.Duration
+	// Fill, when set, prepares the context of loads whose result is cached,
+	// e.g. with database.ReadFromPrimary so a lagging replica isn't cached
+	Fill func(ctx context.Context) context.Context
+
+	group singleflight.Group
+	// random returns a number in [0, 1), rand.Float64 when nil
+	random func() float64
+}
+
+// envelope is how a loaded value or a not found result is cached
+type envelope[T any] struct {
+	Value   T      `json:"v"`
+	Missing bool   `json:"m,omitempty"`
+	Message string `json:"msg,omitempty"`
+	// Delta is how long loading took
+	Delta time.Duration `json:"d"`
+	// Expiry is when the entry expires, in Unix nanoseconds
+	Expiry int64 `json:"e"`
+}
+
+// defaultTimeout bounds loads when Loader.Timeout isn't set
+const defaultTimeout = 5 * time.Second
+
+// errMiss is returned by read when nothing usable is cached under the key
+var errMiss = errors.New("cache miss")
+
+// Get returns the value cached under key, calling load on a miss. Cache
+// failures are treated as misses, so the value is loaded instead. When ctx
+// is done Get returns early, leaving the load to the other callers.
+func (l *Loader[T]) Get(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	e, err := l.read(ctx, key)
+	if err == nil && !l.refreshEarly(e, time.Now()) {
+		return e.result()
+	}
+	// When the cache failed the result isn't stored, so it needn't be filled
+	store := err == nil || errors.Is(err, errMiss)
+
+	ch := l.group.DoChan(key, func() (value interface{}, err error) {
+		// The caller starting the load may go away while others wait for it
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), l.timeout())
+		defer cancel()
+		// DoChan would crash the process with the panic
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("loading %s panicked: %v", key, p)
+			}
+		}()
+		return l.load(ctx, key, load, store)
+	})
+
+	select {
+	case r := <-ch:
+		result, _ := r.Val.(T)
+		return result, r.Err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// read returns the cached entry under key, errMiss when there is none
+func (l *Loader[T]) read(ctx context.Context, key string) (envelope[T], error) {
+	var e envelope[T]
+	cached, err := l.Cache.Get(ctx, key)
+	if err != nil {
+		return e, err
+	}
+	if cached == nil {
+		return e, errMiss
+	}
+	data, ok := cached.(string)
+	if !ok || json.Unmarshal([]byte(data), &e) != nil || e.Expiry == 0 {
+		// Written in another format, e.g. by an older release
+		return e, errMiss
+	}
+	return e, nil
+}
+
+func (l *Loader[T]) load(ctx context.Context, key string, load func(ctx context.Context) (T, error), store bool) (T, error) {
+	if !store {
+		return load(ctx)
+	}
+	if l.Fill != nil {
+		ctx = l.Fill(ctx)
+	}
+
+	start := time.Now()
+	value, err := load(ctx)
+	delta := time.Since(start)
+
+	var appErr *apperrors.Error
+	switch {
+	case ctx.Err() != nil:
+		// Past the timeout the value may be older than an Invalidate's
+		// second delete, which would leave it cached
+	case err == nil:
+		l.write(ctx, key, envelope[T]{Value: value, Delta: delta}, l.TTL)
+	case l.NegativeTTL > 0 && errors.Is(err, apperrors.ErrNotFound) && errors.As(err, &appErr):
+		l.write(ctx, key, envelope[T]{Missing: true, Message: appErr.Message, Delta: delta}, l.NegativeTTL)
+	}
+	return value, err
+}
+
+// Invalidate deletes the keys now and again after Timeout. A load that read
+// the old value before the change committed may still store it, but only
+// within Timeout, so stale values are cached for at most that long.
+func (l *Loader[T]) Invalidate(ctx context.Context, keys ...string) {
+	l.deleteTwice(ctx, func(ctx context.Context) { l.Cache.Delete(ctx, keys...) })
+}
+
+// InvalidatePrefix is Invalidate for every key starting with prefix
+func (l *Loader[T]) InvalidatePrefix(ctx context.Context, prefix string) {
+	l.deleteTwice(ctx, func(ctx context.Context) { l.Cache.DeleteByPrefix(ctx, prefix) })
+}
+
+func (l *Loader[T]) deleteTwice(ctx context.Context, del func(ctx context.Context)) {
+	del(ctx)
+	// The request is over by then
+	ctx = context.WithoutCancel(ctx)
+	time.AfterFunc(l.timeout(), func() { del(ctx) })
+}
+
+// write caches the entry for a jittered ttl. Failures are ignored, the
+// value is loaded again on the next miss.
+func (l *Loader[T]) write(ctx context.Context, key string, e envelope[T], ttl time.Duration) {
+	if l.Jitter > 0 {
+		ttl += time.Duration(float64(ttl) * l.Jitter * (2*l.rand() - 1))
+	}
+	e.Expiry = time.Now().Add(ttl).UnixNano()
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	l.Cache.SetWithTTL(ctx, key, string(data), ttl)
+}
+
+// refreshEarly decides whether this read refreshes the entry ahead of its
+// expiry, as in "Optimal Probabilistic Cache Stampede Prevention"
+func (l *Loader[T]) refreshEarly(e envelope[T], now time.Time) bool {
+	if l.Beta <= 0 {
+		return false
+	}
+	// -log(rand) is exponentially distributed, mostly small but unbounded
+	ahead := time.Duration(float64(e.Delta) * l.Beta * -math.Log(1-l.rand()))
+	return now.Add(ahead).UnixNano() >= e.Expiry
+}
+
+func (l *Loader[T]) timeout() time.Duration {
+	if l.Timeout > 0 {
+		return l.Timeout
+	}
+	return defaultTimeout
+}
+
+func (l *Loader[T]) rand() float64 {
+	if l.random != nil {
+		return l.random()
+	}
+	return rand.Float64()
+}
+
+// result returns the cached value, or the not found error it stands for
+func (e envelope[T]) result() (T, error) {
+	if e.Missing {
+		var zero T
+		return zero, apperrors.NotFound("%s", e.Message)
+	}
+	return e.Value, nil
+}
@@ -0,0 +1,301 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+)
+
+// counting returns a load function returning value and how often it ran
+func counting(value string, err error) (func(ctx context.Context) (string, error), *atomic.Int32) {
+	calls := &atomic.Int32{}
+	return func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		return value, err
+	}, calls
+}
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+	l := &Loader[string]{Cache: &RedisMock{}, TTL: time.Minute, NegativeTTL: time.Minute}
+
+	load, calls := counting("value", nil)
+	for i := 0; i < 3; i++ {
+		value, err := l.Get(ctx, "key", load)
+		assert.NoError(t, err)
+		assert.Equal(t, "value", value)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Not found is cached with its message, other failures aren't
+	load, calls = counting("", apperrors.NotFound("todo %d not found", 7))
+	for i := 0; i < 3; i++ {
+		_, err := l.Get(ctx, "missing", load)
+		assert.ErrorIs(t, err, apperrors.ErrNotFound)
+		assert.EqualError(t, err, "todo 7 not found")
+	}
+	assert.Equal(t, int32(1), calls.Load())
+
+	load, calls = counting("", errors.New("connection refused"))
+	for i := 0; i < 3; i++ {
+		_, err := l.Get(ctx, "failing", load)
+		assert.EqualError(t, err, "connection refused")
+	}
+	assert.Equal(t, int32(3), calls.Load())
+
+	// Values cached in another format are loaded again
+	require.NoError(t, l.Cache.Set(ctx, "old", `"value"`))
+	load, calls = counting("new", nil)
+	value, err := l.Get(ctx, "old", load)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", value)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestLoaderNegativeTTLDisabled(t *testing.T) {
+	ctx := context.Background()
+	l := &Loader[string]{Cache: &RedisMock{}, TTL: time.Minute}
+
+	load, calls := counting("", apperrors.NotFound("not found"))
+	l.Get(ctx, "missing", load)
+	l.Get(ctx, "missing", load)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+// unavailableCache fails every call, as RedisCache does while Redis is down
+type unavailableCache struct{ RedisMock }
+
+func (*unavailableCache) Get(ctx context.Context, key string) (interface{}, error) {
+	return nil, ErrUnavailable
+}
+
+func TestLoaderCacheUnavailable(t *testing.T) {
+	var filled bool
+	l := &Loader[string]{Cache: &unavailableCache{}, TTL: time.Minute, Fill: func(ctx context.Context) context.Context {
+		filled = true
+		return ctx
+	}}
+
+	load, calls := counting("value", nil)
+	value, err := l.Get(context.Background(), "key", load)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, int32(1), calls.Load())
+	// Nothing is cached, so the load isn't a fill
+	assert.False(t, filled)
+}
+
+type fillKey struct{}
+
+func TestLoaderFill(t *testing.T) {
+	l := &Loader[string]{Cache: &RedisMock{}, TTL: time.Minute, Fill: func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, fillKey{}, true)
+	}}
+
+	value, err := l.Get(context.Background(), "key", func(ctx context.Context) (string, error) {
+		if ctx.Value(fillKey{}) == nil {
+			return "unfilled", nil
+		}
+		return "filled", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "filled", value)
+}
+
+func TestLoaderCoalescesMisses(t *testing.T) {
+	ctx := context.Background()
+	l := &Loader[string]{Cache: &RedisMock{}, TTL: time.Minute}
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		<-release
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := l.Get(ctx, "key", load)
+			assert.NoError(t, err)
+			assert.Equal(t, "value", value)
+		}()
+	}
+	// Let every caller reach the load before it completes
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestLoaderLeaderCancelled(t *testing.T) {
+	l := &Loader[string]{Cache: &RedisMock{}, TTL: time.Minute}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return "value", nil
+	}
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error)
+	go func() {
+		_, err := l.Get(leaderCtx, "key", load)
+		leader <- err
+	}()
+	<-started
+
+	follower := make(chan string)
+	go func() {
+		value, err := l.Get(context.Background(), "key", load)
+		assert.NoError(t, err)
+		follower <- value
+	}()
+	// Let the follower join the load
+	time.Sleep(20 * time.Millisecond)
+
+	// The leader gives up without failing the load it started
+	cancel()
+	assert.ErrorIs(t, <-leader, context.Canceled)
+	close(release)
+	assert.Equal(t, "value", <-follower)
+
+	value, err := l.Get(context.Background(), "key", load)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+}
+
+func TestLoaderTimeout(t *testing.T) {
+	l := &Loader[string]{Cache: &RedisMock{}, TTL: time.Minute, Timeout: 10 * time.Millisecond}
+
+	_, err := l.Get(context.Background(), "key", func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = l.Get(context.Background(), "key", func(ctx context.Context) (string, error) {
+		panic("boom")
+	})
+	assert.EqualError(t, err, "loading key panicked: boom")
+}
+
+func TestLoaderInvalidate(t *testing.T) {
+	ctx := context.Background()
+	c := &RedisMock{}
+	l := &Loader[string]{Cache: c, TTL: time.Minute, Timeout: 50 * time.Millisecond}
+
+	// A load reads the old value, then a change commits and invalidates the
+	// key before the load stores what it read
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Get(ctx, "todos:1", func(ctx context.Context) (string, error) {
+			<-release
+			return "old", nil
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	l.Invalidate(ctx, "todos:1")
+	close(release)
+	<-done
+
+	cached, _ := c.Get(ctx, "todos:1")
+	assert.NotNil(t, cached)
+	// The second delete drops it within the timeout
+	assert.Eventually(t, func() bool {
+		cached, _ := c.Get(ctx, "todos:1")
+		return cached == nil
+	}, time.Second, 5*time.Millisecond)
+
+	// Loads outliving the timeout store nothing
+	_, err := l.Get(ctx, "todos:2", func(ctx context.Context) (string, error) {
+		time.Sleep(60 * time.Millisecond)
+		return "old", nil
+	})
+	assert.NoError(t, err)
+	cached, _ = c.Get(ctx, "todos:2")
+	assert.Nil(t, cached)
+
+	l.Get(ctx, "todos:list:1", func(ctx context.Context) (string, error) { return "page", nil })
+	l.InvalidatePrefix(ctx, "todos:list:")
+	cached, _ = c.Get(ctx, "todos:list:1")
+	assert.Nil(t, cached)
+}
+
+func TestLoaderJitter(t *testing.T) {
+	ctx := context.Background()
+	c := &RedisMock{}
+
+	expiry := func(random float64) time.Duration {
+		l := &Loader[string]{Cache: c, TTL: time.Minute, Jitter: 0.1, random: func() float64 { return random }}
+		c.Delete(ctx, "key")
+		start := time.Now()
+		l.Get(ctx, "key", func(ctx context.Context) (string, error) { return "value", nil })
+
+		cached, err := c.Get(ctx, "key")
+		require.NoError(t, err)
+		var e envelope[string]
+		require.NoError(t, json.Unmarshal([]byte(cached.(string)), &e))
+		return time.Unix(0, e.Expiry).Sub(start)
+	}
+
+	assert.InDelta(t, 54*time.Second, expiry(0), float64(time.Second))
+	assert.InDelta(t, time.Minute, expiry(0.5), float64(time.Second))
+	assert.InDelta(t, 66*time.Second, expiry(0.9999), float64(time.Second))
+}
+
+func TestLoaderRefreshEarly(t *testing.T) {
+	now := time.Now()
+	// With 1-1/e the exponential draw is exactly 1, so a refresh comes
+	// Delta * Beta ahead of the expiry
+	l := &Loader[string]{Beta: 1, random: func() float64 { return 1 - 1/math.E }}
+	e := envelope[string]{Delta: 100 * time.Millisecond, Expiry: now.Add(time.Second).UnixNano()}
+
+	assert.False(t, l.refreshEarly(e, now))
+	assert.False(t, l.refreshEarly(e, now.Add(850*time.Millisecond)))
+	assert.True(t, l.refreshEarly(e, now.Add(950*time.Millisecond)))
+
+	l.Beta = 2
+	assert.True(t, l.refreshEarly(e, now.Add(850*time.Millisecond)))
+
+	l.Beta = 0
+	assert.False(t, l.refreshEarly(e, now.Add(950*time.Millisecond)))
+
+	// A refresh loads and stores the value again
+	ctx := context.Background()
+	l = &Loader[string]{Cache: &RedisMock{}, TTL: time.Minute, Beta: 1, random: func() float64 { return 0.5 }}
+	slow := func(ctx context.Context) (string, error) {
+		time.Sleep(5 * time.Millisecond)
+		return "old", nil
+	}
+	l.Get(ctx, "key", slow)
+	load, calls := counting("new", nil)
+	value, _ := l.Get(ctx, "key", load)
+	assert.Equal(t, "old", value)
+
+	l.TTL = time.Millisecond
+	l.Get(ctx, "short", slow)
+	value, _ = l.Get(ctx, "short", load)
+	assert.Equal(t, "new", value)
+	assert.Equal(t, int32(1), calls.Load())
+}
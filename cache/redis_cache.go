@@ -2,24 +2,60 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
+	"github.com/redis/go-redis/v9"
+	"tuhuynh.com/go-ioc-gin-example/circuit"
 	"tuhuynh.com/go-ioc-gin-example/config"
 )
 
+// deleteBatchSize bounds how many keys DeleteByPrefix removes per round trip
+const deleteBatchSize = 100
+
+// ErrUnavailable is returned while Redis is down or not configured. Callers
+// treat it like a miss and fall back to the database.
+var ErrUnavailable = errors.New("cache unavailable")
+
+// RedisCache stores values in Redis. After Redis fails it is skipped for
+// Config.RedisRetryInterval, failing fast with ErrUnavailable, and then
+// tried again.
 type RedisCache struct {
 	Component  struct{}
 	Implements struct{}       `implements:"Cache"`
 	Qualifier  struct{}       `value:"redis"`
 	Config     *config.Config `autowired:"true"`
+
+	breaker circuit.Breaker
 }
 
-func (c *RedisCache) Get(ctx context.Context, key string) (interface{}, error) {
+// client returns the Redis client unless Redis is known to be down
+func (c *RedisCache) client() (*redis.Client, error) {
 	client := c.Config.Redis
-	if client == nil {
-		panic("redis client is nil")
+	if client == nil || !c.breaker.Allow(time.Now()) {
+		return nil, ErrUnavailable
+	}
+	return client, nil
+}
+
+// done records the outcome of a command for the breaker and returns its error
+func (c *RedisCache) done(err error) error {
+	c.breaker.Record(err, time.Now(), c.Config.RedisRetryInterval)
+	return err
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) (interface{}, error) {
+	client, err := c.client()
+	if err != nil {
+		return nil, err
 	}
 
 	val, err := client.Get(ctx, key).Result()
+	c.done(err)
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -27,12 +63,98 @@ func (c *RedisCache) Get(ctx context.Context, key string) (interface{}, error) {
 	return val, nil
 }
 
+// Set stores a value using the configured default TTL
 func (c *RedisCache) Set(ctx context.Context, key string, value interface{}) error {
-	client := c.Config.Redis
-	err := client.Set(ctx, key, value, 0).Err()
+	return c.SetWithTTL(ctx, key, value, c.Config.CacheTTL)
+}
+
+func (c *RedisCache) SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	client, err := c.client()
 	if err != nil {
 		return err
 	}
+	return c.done(client.Set(ctx, key, value, ttl).Err())
+}
+
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	return c.done(client.Del(ctx, keys...).Err())
+}
+
+func (c *RedisCache) GetMulti(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	client, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+	vals, err := client.MGet(ctx, keys...).Result()
+	if err := c.done(err); err != nil {
+		return nil, err
+	}
+
+	for i, val := range vals {
+		if val != nil {
+			values[keys[i]] = val
+		}
+	}
+	return values, nil
+}
+
+func (c *RedisCache) SetMulti(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, value := range values {
+			pipe.Set(ctx, key, value, ttl)
+		}
+		return nil
+	})
+	return c.done(err)
+}
+
+// DeleteByPrefix scans for keys starting with prefix and deletes them in batches.
+// SCAN is used rather than KEYS so a large keyspace doesn't block the server.
+func (c *RedisCache) DeleteByPrefix(ctx context.Context, prefix string) error {
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	iter := client.Scan(ctx, 0, escapeGlob(prefix)+"*", deleteBatchSize).Iterator()
+
+	batch := make([]string, 0, deleteBatchSize)
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == deleteBatchSize {
+			if err := c.done(client.Del(ctx, batch...).Err()); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := c.done(iter.Err()); err != nil {
+		return err
+	}
+
+	return c.Delete(ctx, batch...)
+}
 
-	return nil
+// escapeGlob escapes the characters SCAN MATCH treats as glob patterns
+func escapeGlob(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(s)
 }
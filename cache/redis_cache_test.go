@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tuhuynh.com/go-ioc-gin-example/config"
+)
+
+func newRedisCache(t *testing.T, retryInterval time.Duration) (*RedisCache, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+
+	c := &RedisCache{Config: &config.Config{Redis: client, CacheTTL: time.Hour, RedisRetryInterval: retryInterval}}
+	return c, server
+}
+
+func TestRedisCache(t *testing.T) {
+	ctx := context.Background()
+	c, server := newRedisCache(t, time.Minute)
+
+	value, err := c.Get(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	require.NoError(t, c.SetMulti(ctx, map[string]interface{}{"todos:list:1": "x", "todos:1": "y"}, time.Minute))
+	require.NoError(t, c.DeleteByPrefix(ctx, "todos:list:"))
+	values, err := c.GetMulti(ctx, []string{"todos:list:1", "todos:1"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"todos:1": "y"}, values)
+
+	require.NoError(t, c.SetWithTTL(ctx, "short", "v", time.Second))
+	server.FastForward(2 * time.Second)
+	value, err = c.Get(ctx, "short")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+func TestRedisCacheUnavailable(t *testing.T) {
+	ctx := context.Background()
+
+	// Without a client every call fails fast instead of panicking
+	c := &RedisCache{Config: &config.Config{}}
+	_, err := c.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.ErrorIs(t, c.Set(ctx, "key", "v"), ErrUnavailable)
+	assert.ErrorIs(t, c.DeleteByPrefix(ctx, "todos:"), ErrUnavailable)
+
+	// After a failure Redis is skipped until the retry interval has passed
+	c, server := newRedisCache(t, 50*time.Millisecond)
+	require.NoError(t, c.Set(ctx, "key", "v"))
+	server.Close()
+
+	_, err = c.Get(ctx, "key")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnavailable)
+	_, err = c.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrUnavailable)
+
+	require.NoError(t, server.Restart())
+	time.Sleep(60 * time.Millisecond)
+	value, err := c.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "v", value)
+}
@@ -2,7 +2,9 @@ package cache
 
 import (
 	"context"
+	"strings"
 	"sync"
+	"time"
 )
 
 // RedisMock is a mock implementation of Cache interface for testing
@@ -17,13 +19,67 @@ type RedisMock struct {
 // Get retrieves a value from the mock cache
 func (m *RedisMock) Get(ctx context.Context, key string) (interface{}, error) {
 	if value, ok := m.store.Load(key); ok {
-		return value, nil
+		e := value.(*entry)
+		if e.expired(time.Now()) {
+			m.store.CompareAndDelete(key, value)
+			return nil, nil
+		}
+		return e.value, nil
 	}
 	return nil, nil
 }
 
-// Set stores a value in the mock cache
+// Set stores a value in the mock cache without expiration
 func (m *RedisMock) Set(ctx context.Context, key string, value interface{}) error {
-	m.store.Store(key, value)
+	return m.SetWithTTL(ctx, key, value, 0)
+}
+
+// SetWithTTL stores a value in the mock cache that expires after ttl
+func (m *RedisMock) SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	e := &entry{key: key, value: value}
+	if ttl > 0 {
+		e.expiresAt = time.Now().Add(ttl)
+	}
+	m.store.Store(key, e)
+	return nil
+}
+
+// Delete removes the given keys from the mock cache
+func (m *RedisMock) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		m.store.Delete(key)
+	}
+	return nil
+}
+
+// GetMulti retrieves the values present in the mock cache for the given keys
+func (m *RedisMock) GetMulti(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		if value, _ := m.Get(ctx, key); value != nil {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// SetMulti stores several values in the mock cache with the same ttl
+func (m *RedisMock) SetMulti(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	for key, value := range values {
+		if err := m.SetWithTTL(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteByPrefix removes every key starting with prefix from the mock cache
+func (m *RedisMock) DeleteByPrefix(ctx context.Context, prefix string) error {
+	m.store.Range(func(key, _ interface{}) bool {
+		if strings.HasPrefix(key.(string), prefix) {
+			m.store.Delete(key)
+		}
+		return true
+	})
 	return nil
 }
@@ -0,0 +1,33 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Breaker skips a failing backend for a while, so an outage doesn't add a
+// timeout to every request. The first call after the pause tries it again.
+type Breaker struct {
+	openUntil atomic.Int64 // unix nanoseconds
+}
+
+// Allow reports whether the backend should be called
+func (b *Breaker) Allow(now time.Time) bool {
+	return now.UnixNano() >= b.openUntil.Load()
+}
+
+// Record pauses calls for the given duration when err means the backend is
+// failing. Misses and cancelled requests say nothing about its health.
+func (b *Breaker) Record(err error, now time.Time, pause time.Duration) {
+	switch {
+	case err == nil:
+		b.openUntil.Store(0)
+	case errors.Is(err, redis.Nil), errors.Is(err, context.Canceled):
+	default:
+		b.openUntil.Store(now.Add(pause).UnixNano())
+	}
+}
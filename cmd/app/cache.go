@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"tuhuynh.com/go-ioc-gin-example/wire"
+)
+
+// cacheCommand flushes cached todos. Only keys under --prefix are deleted, so
+// rate limit counters sharing the Redis database are kept.
+func cacheCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "flush" {
+		return errUsage
+	}
+
+	flags := flag.NewFlagSet("cache flush", flag.ContinueOnError)
+	prefix := flags.String("prefix", "todos:", "prefix of the keys to delete")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 || *prefix == "" {
+		return errUsage
+	}
+
+	return withContainer(func(container *wire.Container) error {
+		if err := container.RedisCache.DeleteByPrefix(ctx, *prefix); err != nil {
+			return err
+		}
+		fmt.Printf("Flushed cache keys starting with %q\n", *prefix)
+		return nil
+	})
+}
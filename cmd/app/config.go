@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"tuhuynh.com/go-ioc-gin-example/config"
+)
+
+// configCommand prints the configuration the server would run with, and
+// where each setting came from. Unlike the other commands it doesn't
+// connect to MySQL or Redis.
+func configCommand(ctx context.Context, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errUsage
+	}
+
+	cfg, err := config.LoadFromFlags()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, setting := range cfg.Settings() {
+		fmt.Fprintf(w, "%s=%s\t# %s\n", setting.Name, setting.Value, setting.Source)
+	}
+	return w.Flush()
+}
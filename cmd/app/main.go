@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/wire"
+)
+
+// command is a subcommand of the CLI
+type command struct {
+	usage   string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"serve":   {"serve", "run the HTTP server", serve},
+	"migrate": {"migrate up|down [N]|status|redo", "apply, revert or list database migrations", migrate},
+	"seed":    {"seed [--count N] [--owner SUBJECT]", "create random todos", seed},
+	"cache":   {"cache flush [--prefix PREFIX]", "delete cached todos", cacheCommand},
+	"config":  {"config print", "print the effective configuration", configCommand},
+}
+
+// commandOrder is the order commands are listed in the usage
+var commandOrder = []string{"serve", "migrate", "seed", "cache", "config"}
+
+// errUsage reports invalid arguments; the usage is printed instead of an error
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	if os.Getenv("GO_ENV") != "production" {
+		if err := godotenv.Load(); err != nil {
+			log.Printf("Warning: .env file not found: %v", err)
+		}
+	}
+
+	// Config flags come before the command, e.g. app -server.port 9090 serve
+	flags := flag.NewFlagSet("app", flag.ContinueOnError)
+	flags.Usage = printUsage
+	config.RegisterFlags(flags)
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	args := flags.Args()
+	if len(args) < 1 {
+		printUsage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		printUsage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	err := cmd.run(ctx, args[1:])
+	stop()
+
+	switch {
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		fmt.Fprintf(os.Stderr, "Usage: app %s\n", cmd.usage)
+		os.Exit(2)
+	case err != nil:
+		log.Printf("%s: %v", args[0], err)
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: app [-config FILE] [-<setting> VALUE ...] <command> [arguments]\n\nCommands:")
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, name := range commandOrder {
+		fmt.Fprintf(w, "  %s\t%s\n", commands[name].usage, commands[name].summary)
+	}
+	w.Flush()
+	fmt.Fprintln(os.Stderr, "\nSettings are listed by app config print, e.g. -server.port 9090 -log.level debug")
+}
+
+// withContainer builds the same components as the server, runs fn and
+// destroys them again
+func withContainer(fn func(container *wire.Container) error) error {
+	container, cleanup := wire.Initialize()
+	defer cleanup()
+	return fn(container)
+}
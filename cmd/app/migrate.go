@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"tuhuynh.com/go-ioc-gin-example/wire"
+)
+
+// migrate applies, reverts or lists the versioned migrations
+func migrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "up", "redo", "status":
+		if len(args) > 1 {
+			return errUsage
+		}
+	case "down":
+		if len(args) > 2 {
+			return errUsage
+		}
+	default:
+		return errUsage
+	}
+
+	n := 1
+	if args[0] == "down" && len(args) == 2 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+			return fmt.Errorf("invalid count %q", args[1])
+		}
+	}
+
+	return withContainer(func(container *wire.Container) error {
+		runner := container.Runner
+
+		switch args[0] {
+		case "up":
+			return runner.Up(ctx)
+		case "down":
+			return runner.Down(ctx, n)
+		case "redo":
+			return runner.Redo(ctx)
+		}
+
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			appliedAt := ""
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%-6d %-32s %-9s %s\n", s.Version, s.Name, s.State, appliedAt)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+
+	"tuhuynh.com/go-ioc-gin-example/entities"
+	"tuhuynh.com/go-ioc-gin-example/security"
+	"tuhuynh.com/go-ioc-gin-example/wire"
+)
+
+// seedBatchSize is how many todos are created per transaction
+const seedBatchSize = 1000
+
+var (
+	seedActions = []string{"Complete", "Start", "Review", "Update", "Prepare", "Analyze", "Implement", "Test", "Document", "Deploy"}
+	seedTitles  = []string{"Meeting", "Review", "Project", "Task", "Planning", "Research", "Development", "Testing", "Documentation", "Deployment"}
+)
+
+// seed creates random todos owned by --owner through the todo service, so
+// cached listings are invalidated as for API writes. The todos are created
+// in transactions of seedBatchSize, so large seeds don't hold one long
+// transaction, and a failed seed leaves only whole batches behind.
+func seed(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	count := flags.Int("count", 100, "number of todos to create")
+	owner := flags.String("owner", "service:seed", "subject owning the todos")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 || *count < 1 || *owner == "" {
+		return errUsage
+	}
+
+	ctx = security.WithPrincipal(ctx, security.Principal{Subject: *owner, Method: "cli"})
+
+	return withContainer(func(container *wire.Container) error {
+		for created := 0; created < *count; created += seedBatchSize {
+			batch := min(seedBatchSize, *count-created)
+			err := container.TxManagerSql.WithinTx(ctx, func(ctx context.Context) error {
+				for i := 1; i <= batch; i++ {
+					title := seedActions[rand.Intn(len(seedActions))] + " " + seedTitles[rand.Intn(len(seedTitles))]
+					todo := entities.Todo{
+						Title:       title,
+						Description: "Description for: " + title,
+						Completed:   rand.Intn(4) == 0,
+					}
+					if err := container.TodoServiceImpl.Create(ctx, todo); err != nil {
+						return fmt.Errorf("failed to create todo #%d: %w", created+i, err)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("%w (created %d todos before)", err, created)
+			}
+		}
+
+		fmt.Printf("Created %d todos for %s\n", *count, *owner)
+		return nil
+	})
+}
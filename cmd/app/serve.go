@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"tuhuynh.com/go-ioc-gin-example/wire"
+)
+
+// serve runs the HTTP server until ctx is cancelled by SIGINT or SIGTERM
+func serve(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	container, cleanup := wire.Initialize()
+
+	// Run application in goroutine
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- container.Application.Start(ctx)
+	}()
+
+	// Wait for interrupt signal or for the server to fail
+	var err error
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down gracefully...")
+	case err = <-errChan:
+	}
+
+	// Drain requests before the components they depend on are destroyed
+	if err := container.Application.Shutdown(context.Background()); err != nil {
+		log.Printf("Error during shutdown: %v", err)
+	}
+
+	cleanup()
+	// A server that failed to start or stopped unexpectedly exits non-zero
+	return err
+}
@@ -2,35 +2,160 @@ package config
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/redis/go-redis/v9"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"tuhuynh.com/go-ioc-gin-example/health"
 )
 
+// Config holds the settings of every component and the connections built
+// from them. Each setting is tagged with its key in the config file and on
+// the command line, its environment variable and its default; see Load for
+// how they are layered.
 type Config struct {
 	Component struct{}
 	DB        *gorm.DB
+	Replicas  []*gorm.DB
 	Redis     *redis.Client
-	Port      string
-	AppMode   string
+
+	// Server
+	Port    int    `config:"server.port" env:"APP_PORT" default:"8080"`
+	AppMode string `config:"server.mode" env:"APP_MODE" default:"local"`
+	// ShutdownTimeout bounds how long in-flight requests are drained on shutdown
+	ShutdownTimeout time.Duration `config:"server.shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"30s"`
+	// ShutdownDelay is how long readiness fails before connections start draining
+	ShutdownDelay time.Duration `config:"server.shutdown_delay" env:"SHUTDOWN_DELAY" default:"0s"`
+	// HealthCheckTimeout bounds each dependency check of the readiness probe
+	HealthCheckTimeout time.Duration `config:"server.health_check_timeout" env:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+
+	// Database
+	// DBDriver is one of the Driver* dialects. With SQLite, DBName is the
+	// path of the database file and the host, port and credentials are unused.
+	DBDriver   string `config:"db.driver" env:"DB_DRIVER" default:"mysql"`
+	DBHost     string `config:"db.host" env:"DB_HOST" default:"localhost"`
+	DBPort     int    `config:"db.port" env:"DB_PORT" default:"3306"`
+	DBUser     string `config:"db.user" env:"DB_USER" default:"myuser"`
+	DBPassword string `config:"db.password" env:"DB_PASSWORD" default:"mypassword" secret:"true"`
+	DBName     string `config:"db.name" env:"DB_NAME" default:"mydatabase"`
+	// DBSSLMode is the PostgreSQL sslmode, such as disable, require or verify-full
+	DBSSLMode string `config:"db.ssl_mode" env:"DB_SSL_MODE" default:"disable"`
+	// DBMaxOpenConns and DBMaxIdleConns size the connection pool
+	DBMaxOpenConns int `config:"db.max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"25"`
+	DBMaxIdleConns int `config:"db.max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"10"`
+	// DBConnMaxLifetime and DBConnMaxIdleTime recycle pooled connections, 0 keeps them forever
+	DBConnMaxLifetime time.Duration `config:"db.conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" default:"30m"`
+	DBConnMaxIdleTime time.Duration `config:"db.conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" default:"5m"`
+	// DBConnectTimeout is how long startup retries connecting before giving up
+	DBConnectTimeout time.Duration `config:"db.connect_timeout" env:"DB_CONNECT_TIMEOUT" default:"30s"`
+	// DBReplicas are the DSNs of read replicas, in the format of DBDriver,
+	// that uncached reads are spread over. Cache fills read from the primary,
+	// so replicas mostly serve reads while Redis is down.
+	DBReplicas []string `config:"db.replicas" env:"DB_REPLICAS" secret:"true"`
+	// DBReplicaCheckInterval is how often replicas are pinged, ejecting those
+	// that fail until they answer again
+	DBReplicaCheckInterval time.Duration `config:"db.replica_check_interval" env:"DB_REPLICA_CHECK_INTERVAL" default:"5s"`
+	// DBReadYourWritesWindow is how long reads of a request go to the primary
+	// after it wrote, so they see the write despite replication lag
+	DBReadYourWritesWindow time.Duration `config:"db.read_your_writes_window" env:"DB_READ_YOUR_WRITES_WINDOW" default:"5s"`
+	// MigrateOnBoot is what the server does with the schema on start, one of
+	// the MigrateOnBoot* modes
+	MigrateOnBoot string `config:"db.migrate_on_boot" env:"MIGRATE_ON_BOOT" default:"auto"`
+	// DBLegacyOwner is the subject migration 4 assigns the todos created
+	// before todos had owners to
+	DBLegacyOwner string `config:"db.legacy_owner" env:"DB_LEGACY_OWNER"`
+
+	// Redis
+	RedisHost     string `config:"redis.host" env:"REDIS_HOST" default:"localhost"`
+	RedisPort     int    `config:"redis.port" env:"REDIS_PORT" default:"6379"`
+	RedisPassword string `config:"redis.password" env:"REDIS_PASSWORD" secret:"true"`
+	RedisDB       int    `config:"redis.db" env:"REDIS_DB" default:"0"`
+	// RedisPoolSize caps the connections per replica, 0 uses 10 per CPU
+	RedisPoolSize     int `config:"redis.pool_size" env:"REDIS_POOL_SIZE" default:"0"`
+	RedisMinIdleConns int `config:"redis.min_idle_conns" env:"REDIS_MIN_IDLE_CONNS" default:"0"`
+	// RedisDialTimeout and RedisTimeout bound connecting and each command, so
+	// an unreachable Redis slows requests down as little as possible
+	RedisDialTimeout time.Duration `config:"redis.dial_timeout" env:"REDIS_DIAL_TIMEOUT" default:"1s"`
+	RedisTimeout     time.Duration `config:"redis.timeout" env:"REDIS_TIMEOUT" default:"500ms"`
+	// RedisConnectTimeout is how long startup retries connecting before
+	// continuing without Redis
+	RedisConnectTimeout time.Duration `config:"redis.connect_timeout" env:"REDIS_CONNECT_TIMEOUT" default:"5s"`
+	// RedisRetryInterval is how long Redis is skipped after failing before
+	// it is tried again
+	RedisRetryInterval time.Duration `config:"redis.retry_interval" env:"REDIS_RETRY_INTERVAL" default:"5s"`
+
+	// Cache
+	CacheTTL     time.Duration `config:"cache.ttl" env:"CACHE_TTL" default:"1h"`
+	CacheListTTL time.Duration `config:"cache.list_ttl" env:"CACHE_LIST_TTL" default:"1m"`
+	CacheItemTTL time.Duration `config:"cache.item_ttl" env:"CACHE_ITEM_TTL" default:"10m"`
+	// CacheNegativeTTL is how long a lookup that found nothing is remembered
+	CacheNegativeTTL time.Duration `config:"cache.negative_ttl" env:"CACHE_NEGATIVE_TTL" default:"10s"`
+	// CacheTTLJitter spreads expiries by up to this fraction of the TTL either
+	// way, so keys cached together don't expire together
+	CacheTTLJitter float64 `config:"cache.ttl_jitter" env:"CACHE_TTL_JITTER" default:"0.1"`
+	// CacheEarlyRefreshBeta makes hot keys more likely to be refreshed before
+	// they expire the larger it is, 0 disables early refresh
+	CacheEarlyRefreshBeta float64 `config:"cache.early_refresh_beta" env:"CACHE_EARLY_REFRESH_BETA" default:"1"`
+	// CacheLoadTimeout bounds loading a missed key. The load is shared by
+	// every request missing the key, so it isn't cancelled with any of them.
+	CacheLoadTimeout time.Duration `config:"cache.load_timeout" env:"CACHE_LOAD_TIMEOUT" default:"5s"`
+
+	// Authentication
+	// JWTSecret verifies HS256 bearer tokens
+	JWTSecret string `config:"auth.jwt_secret" env:"JWT_SECRET" secret:"true"`
+	// JWTJWKSFile is a JWKS file holding the public keys that verify RS256 bearer tokens
+	JWTJWKSFile string `config:"auth.jwt_jwks_file" env:"JWT_JWKS_FILE"`
+	// JWTIssuer and JWTAudience, when set, must match the iss and aud claims
+	JWTIssuer   string `config:"auth.jwt_issuer" env:"JWT_ISSUER"`
+	JWTAudience string `config:"auth.jwt_audience" env:"JWT_AUDIENCE"`
+
+	// Rate limiting
+	RateLimitRead      Rate   `config:"rate_limit.read" env:"RATE_LIMIT_READ" default:"300/1m"`
+	RateLimitWrite     Rate   `config:"rate_limit.write" env:"RATE_LIMIT_WRITE" default:"50/1m"`
+	RateLimitAlgorithm string `config:"rate_limit.algorithm" env:"RATE_LIMIT_ALGORITHM" default:"token_bucket"`
+	RateLimitKey       string `config:"rate_limit.key" env:"RATE_LIMIT_KEY" default:"ip"`
+	// RateLimitAuth limits every request by IP before it is authenticated,
+	// so failed authentication attempts are limited too
+	RateLimitAuth Rate `config:"rate_limit.auth" env:"RATE_LIMIT_AUTH" default:"600/1m"`
+	// RateLimits holds the rate limit policies by name, "auth", "read" and "write"
+	RateLimits map[string]RateLimitPolicy
+	// RateLimitMaxKeys caps the buckets tracked by the in-memory rate limiter
+	RateLimitMaxKeys int `config:"rate_limit.max_keys" env:"RATE_LIMIT_MAX_KEYS" default:"100000"`
+	// RateLimitJanitorInterval is how often idle in-memory buckets are evicted
+	RateLimitJanitorInterval time.Duration `config:"rate_limit.janitor_interval" env:"RATE_LIMIT_JANITOR_INTERVAL" default:"1m"`
+
+	// Logging
+	// LogLevel and LogFormat default to debug and console output, or to info
+	// and JSON when AppMode is production
+	LogLevel  string `config:"log.level" env:"LOG_LEVEL"`
+	LogFormat string `config:"log.format" env:"LOG_FORMAT"`
+
+	// sources records where each setting came from, by key
+	sources map[string]string
 }
 
+// NewConfig loads and validates the configuration, exiting with every
+// invalid setting listed when it isn't valid, and connects to the database,
+// its replicas and Redis
 func NewConfig() *Config {
-	appPort := getEnvOrDefault("APP_PORT", "8080")
-	appMode := getEnvOrDefault("APP_MODE", "local")
-
-	return &Config{
-		DB:      initDB(),
-		Redis:   initRedis(),
-		Port:    fmt.Sprintf(":%s", appPort),
-		AppMode: appMode,
+	c, err := LoadFromFlags()
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
+
+	c.DB = initDB(c)
+	c.Replicas = initReplicas(c)
+	c.Redis = initRedis(c)
+	return c
+}
+
+// Addr is the address the HTTP server listens on
+func (c *Config) Addr() string {
+	return fmt.Sprintf(":%d", c.Port)
 }
 
 func (c *Config) PreDestroy() {
@@ -56,72 +181,66 @@ func (c *Config) PreDestroy() {
 			}
 		}
 	}
-}
 
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	// Close read replica connections
+	for i, replica := range c.Replicas {
+		if sqlDB, err := replica.DB(); err == nil {
+			if err := sqlDB.Close(); err != nil {
+				log.Printf("Error closing read replica %d: %v", i+1, err)
+			}
+		}
 	}
-	return defaultValue
 }
 
-func initDB() *gorm.DB {
-	dbUser := getEnvOrDefault("DB_USER", "myuser")
-	dbPassword := getEnvOrDefault("DB_PASSWORD", "mypassword")
-	dbName := getEnvOrDefault("DB_NAME", "mydatabase")
-	dbHost := getEnvOrDefault("DB_HOST", "localhost")
-	dbPort := getEnvOrDefault("DB_PORT", "3306")
-
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-		dbUser,
-		dbPassword,
-		dbHost,
-		dbPort,
-		dbName,
-	)
-
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("failed to connect database: %v", err)
-	}
-
-	sqlDB, err := db.DB()
-	if err != nil {
-		log.Fatalf("failed to get underlying *sql.DB: %v", err)
+// HealthChecks probes the database, which is critical, and Redis, which the
+// service can run without
+func (c *Config) HealthChecks() []health.Check {
+	return []health.Check{
+		{
+			Name:     "database",
+			Critical: true,
+			Timeout:  c.HealthCheckTimeout,
+			Probe: func(ctx context.Context) error {
+				if c.DB == nil {
+					return errors.New("database not configured")
+				}
+				sqlDB, err := c.DB.DB()
+				if err != nil {
+					return err
+				}
+				return sqlDB.PingContext(ctx)
+			},
+		},
+		{
+			Name:     "redis",
+			Critical: false,
+			Timeout:  c.HealthCheckTimeout,
+			Probe: func(ctx context.Context) error {
+				if c.Redis == nil {
+					return errors.New("redis client not configured")
+				}
+				return c.Redis.Ping(ctx).Err()
+			},
+		},
 	}
-
-	// Test the connection
-	err = sqlDB.Ping()
-	if err != nil {
-		log.Fatalf("failed to ping database: %v", err)
-	}
-
-	log.Println("Successfully connected to database")
-	return db
 }
 
-func initRedis() *redis.Client {
-	log.Println("Initializing redis client")
-	redisHost := getEnvOrDefault("REDIS_HOST", "localhost")
-	redisPort := getEnvOrDefault("REDIS_PORT", "6379")
-	redisPassword := getEnvOrDefault("REDIS_PASSWORD", "")
-
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", redisHost, redisPort),
-		Password: redisPassword,
-		DB:       0, // use default DB
-	})
-
-	// Test the connection with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	_, err := client.Ping(ctx).Result()
-	if err != nil {
-		log.Printf("failed to ping redis: %v", err)
-		return nil
-	}
+// Database dialects of DBDriver
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
 
-	log.Println("Successfully connected to redis")
-	return client
-}
+// defaultPostgresPort replaces the MySQL default of DBPort for PostgreSQL
+const defaultPostgresPort = 5432
+
+// Modes of MigrateOnBoot
+const (
+	// MigrateOnBootOff leaves the schema to the migrate command
+	MigrateOnBootOff = "off"
+	// MigrateOnBootAuto applies pending migrations, one replica at a time
+	MigrateOnBootAuto = "auto"
+	// MigrateOnBootVerifyOnly keeps the replica unready while migrations are pending
+	MigrateOnBootVerifyOnly = "verify-only"
+)
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Backoff between connection attempts at startup
+const (
+	initialBackoff = 100 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// retry calls connect until it succeeds or timeout has passed, doubling the
+// pause between attempts
+func retry(name string, timeout time.Duration, connect func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		err := connect(ctx)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up connecting to %s after %d attempts in %s: %w", name, attempt, timeout, err)
+		case <-time.After(backoff):
+		}
+		log.Printf("Connecting to %s failed, retrying (attempt %d): %v", name, attempt, err)
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// dialector opens the database of c.DBDriver
+func dialector(c *Config) (gorm.Dialector, error) {
+	switch c.DBDriver {
+	case DriverMySQL:
+		cfg := mysqldriver.NewConfig()
+		cfg.User = c.DBUser
+		cfg.Passwd = c.DBPassword
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(c.DBHost, strconv.Itoa(c.DBPort))
+		cfg.DBName = c.DBName
+		cfg.ParseTime = true
+		return mysql.Open(cfg.FormatDSN()), nil
+	case DriverPostgres:
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(c.DBUser, c.DBPassword),
+			Host:     net.JoinHostPort(c.DBHost, strconv.Itoa(c.DBPort)),
+			Path:     c.DBName,
+			RawQuery: url.Values{"sslmode": {c.DBSSLMode}}.Encode(),
+		}
+		return postgres.Open(dsn.String()), nil
+	case DriverSQLite:
+		// Wait for the write lock instead of failing at once, and enforce
+		// foreign keys as the other dialects do
+		return sqlite.Open("file:" + c.DBName + "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)"), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", c.DBDriver)
+	}
+}
+
+// initDB connects to the database, retrying for up to DBConnectTimeout. The
+// service can't run without its database, so it exits when that doesn't
+// succeed. Connections lost later are re-established by the pool as they are
+// needed.
+func initDB(c *Config) *gorm.DB {
+	dialect, err := dialector(c)
+	if err != nil {
+		log.Fatalf("failed to connect database: %v", err)
+	}
+
+	var db *gorm.DB
+	err = retry("database", c.DBConnectTimeout, func(ctx context.Context) error {
+		var err error
+		db, err = gorm.Open(dialect, &gorm.Config{
+			// Surface duplicate key and foreign key failures as gorm's dialect neutral errors
+			TranslateError: true,
+		})
+		if err != nil {
+			return err
+		}
+
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			sqlDB.Close()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("failed to connect database: %v", err)
+	}
+
+	sqlDB := configurePool(db, c)
+	if c.DBDriver == DriverSQLite && c.DBName == ":memory:" {
+		// Every connection would open a database of its own
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
+
+	log.Printf("Successfully connected to %s database", c.DBDriver)
+	return db
+}
+
+// initReplicas opens the read replicas without connecting to them, so one
+// that is down doesn't hold up startup. Reads avoid it until it answers.
+func initReplicas(c *Config) []*gorm.DB {
+	replicas := make([]*gorm.DB, 0, len(c.DBReplicas))
+	for i, dsn := range c.DBReplicas {
+		var dialect gorm.Dialector
+		switch c.DBDriver {
+		case DriverMySQL:
+			dialect = mysql.New(mysql.Config{DSN: dsn, SkipInitializeWithVersion: true})
+		case DriverPostgres:
+			dialect = postgres.Open(dsn)
+		default:
+			log.Fatalf("read replicas aren't supported with %s", c.DBDriver)
+		}
+
+		db, err := gorm.Open(dialect, &gorm.Config{TranslateError: true, DisableAutomaticPing: true})
+		if err != nil {
+			// The DSN isn't logged as it holds the password
+			log.Fatalf("invalid read replica %d: %v", i+1, err)
+		}
+		configurePool(db, c)
+		replicas = append(replicas, db)
+	}
+	if len(replicas) > 0 {
+		log.Printf("Opened %d read replicas", len(replicas))
+	}
+	return replicas
+}
+
+// configurePool sizes the connection pool of db
+func configurePool(db *gorm.DB, c *Config) *sql.DB {
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("failed to get underlying *sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(c.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(c.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(c.DBConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(c.DBConnMaxIdleTime)
+	return sqlDB
+}
+
+// initRedis creates the Redis client, retrying the first connection for up
+// to RedisConnectTimeout. The client is returned even when Redis is down:
+// the service then runs without it, and the client reconnects on its own
+// once Redis is back.
+func initRedis(c *Config) *redis.Client {
+	log.Println("Initializing redis client")
+
+	client := redis.NewClient(&redis.Options{
+		Addr:         fmt.Sprintf("%s:%d", c.RedisHost, c.RedisPort),
+		Password:     c.RedisPassword,
+		DB:           c.RedisDB,
+		PoolSize:     c.RedisPoolSize,
+		MinIdleConns: c.RedisMinIdleConns,
+		DialTimeout:  c.RedisDialTimeout,
+		ReadTimeout:  c.RedisTimeout,
+		WriteTimeout: c.RedisTimeout,
+		// Give up on a command rather than retrying it against a Redis that is down
+		MaxRetries: 1,
+	})
+
+	err := retry("redis", c.RedisConnectTimeout, func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	})
+	if err != nil {
+		log.Printf("Redis unavailable, running without it until it is back: %v", err)
+		return client
+	}
+
+	log.Println("Successfully connected to redis")
+	return client
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+)
+
+func TestRetry(t *testing.T) {
+	attempts := 0
+	err := retry("test", time.Second, func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = retry("test", 250*time.Millisecond, func(ctx context.Context) error {
+		attempts++
+		return errors.New("connection refused")
+	})
+	assert.ErrorContains(t, err, "gave up connecting to test")
+	assert.ErrorContains(t, err, "connection refused")
+	// Attempts at 0, 100ms and 300ms with the backoff doubling
+	assert.Equal(t, 2, attempts)
+}
+
+func TestDialector(t *testing.T) {
+	c := &Config{DBDriver: DriverMySQL, DBHost: "db", DBPort: 3306, DBUser: "app", DBPassword: "p@ss:/word", DBName: "todos"}
+	d, err := dialector(c)
+	require.NoError(t, err)
+	assert.Equal(t, "app:p@ss:/word@tcp(db:3306)/todos?parseTime=true", d.(*mysql.Dialector).DSN)
+
+	c.DBDriver, c.DBPort, c.DBSSLMode = DriverPostgres, 5432, "require"
+	d, err = dialector(c)
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://app:p%40ss%3A%2Fword@db:5432/todos?sslmode=require", d.(*postgres.Dialector).DSN)
+
+	c.DBDriver = "oracle"
+	_, err = dialector(c)
+	assert.ErrorContains(t, err, "unsupported database driver")
+}
+
+func TestInitDBSQLite(t *testing.T) {
+	c := &Config{DBDriver: DriverSQLite, DBName: filepath.Join(t.TempDir(), "app.db"), DBConnectTimeout: time.Second, DBMaxOpenConns: 4}
+	db := initDB(c)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	var foreignKeys int
+	require.NoError(t, db.Raw("PRAGMA foreign_keys").Scan(&foreignKeys).Error)
+	assert.Equal(t, 1, foreignKeys)
+	var journalMode string
+	require.NoError(t, db.Raw("PRAGMA journal_mode").Scan(&journalMode).Error)
+	assert.Equal(t, "wal", journalMode)
+}
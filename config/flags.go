@@ -0,0 +1,46 @@
+package config
+
+import (
+	"flag"
+	"os"
+)
+
+var (
+	// flagFile and flagValues are set by the flags of RegisterFlags
+	flagFile   string
+	flagValues = make(map[string]string)
+)
+
+// RegisterFlags adds -config, naming the config file, and a flag for every
+// setting, named by its key such as -server.port, to fs. NewConfig applies
+// the flags that were set on top of the file and the environment. Secrets
+// get no flag: the command line is visible to every user of the host.
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&flagFile, "config", "", "YAML or TOML config file (default $CONFIG_FILE)")
+
+	for _, s := range (&Config{}).settings() {
+		if s.secret {
+			continue
+		}
+		key := s.key
+		usage := "sets " + s.describe()
+		fs.Func(key, usage, func(value string) error {
+			flagValues[key] = value
+			return nil
+		})
+	}
+}
+
+// LoadFromFlags loads the configuration with the file and settings given by
+// the flags of RegisterFlags
+func LoadFromFlags() (*Config, error) {
+	return Load(configFile(), flagValues)
+}
+
+// configFile is the config file named by -config or CONFIG_FILE
+func configFile() string {
+	if flagFile != "" {
+		return flagFile
+	}
+	return os.Getenv("CONFIG_FILE")
+}
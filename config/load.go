@@ -0,0 +1,261 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Where a setting came from, in increasing precedence
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// setting is a field of Config that can be configured
+type setting struct {
+	key    string
+	env    string
+	def    string
+	secret bool
+	value  reflect.Value
+}
+
+// settings returns the configurable fields of c in declaration order
+func (c *Config) settings() []setting {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	var settings []setting
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, ok := field.Tag.Lookup("config")
+		if !ok {
+			continue
+		}
+		settings = append(settings, setting{
+			key:    key,
+			env:    field.Tag.Get("env"),
+			def:    field.Tag.Get("default"),
+			secret: field.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+	return settings
+}
+
+// Load builds the configuration from, in increasing precedence, the defaults,
+// the YAML or TOML file at path if set, the environment and flags, keyed by
+// setting such as "server.port". It fails listing every invalid setting.
+func Load(path string, flags map[string]string) (*Config, error) {
+	c := &Config{sources: make(map[string]string)}
+
+	var file map[string]string
+	var problems []string
+	if path != "" {
+		var err error
+		if file, err = readFile(path); err != nil {
+			return nil, fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+
+	known := make(map[string]bool)
+	invalid := make(map[string]bool)
+	for _, s := range c.settings() {
+		known[s.key] = true
+
+		value, source := s.def, SourceDefault
+		if v, ok := file[s.key]; ok {
+			value, source = v, SourceFile
+		}
+		if v, ok := os.LookupEnv(s.env); ok && s.env != "" && v != "" {
+			value, source = v, SourceEnv
+		}
+		if v, ok := flags[s.key]; ok {
+			if s.secret {
+				problems = append(problems, fmt.Sprintf("%s: is secret, set it in a file or the environment instead of a flag", s.describe()))
+				invalid[s.key] = true
+				continue
+			}
+			value, source = v, SourceFlag
+		}
+
+		if err := parseInto(s.value, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid value %q from %s: %v", s.describe(), display(s, value), source, err))
+			invalid[s.key] = true
+			continue
+		}
+		c.sources[s.key] = source
+	}
+
+	for _, unknown := range unknownKeys(file, known) {
+		problems = append(problems, fmt.Sprintf("%s: unknown setting in %s", unknown, path))
+	}
+	for _, unknown := range unknownKeys(flags, known) {
+		problems = append(problems, fmt.Sprintf("%s: unknown setting in flags", unknown))
+	}
+
+	if c.DBDriver == DriverPostgres && c.sources["db.port"] == SourceDefault {
+		c.DBPort = defaultPostgresPort
+	}
+
+	problems = append(problems, c.validate(invalid)...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	c.RateLimits = c.rateLimits()
+	return c, nil
+}
+
+// ValidationError lists every invalid setting
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// describe names the setting by key and environment variable
+func (s setting) describe() string {
+	if s.env == "" {
+		return s.key
+	}
+	return fmt.Sprintf("%s (%s)", s.key, s.env)
+}
+
+func display(s setting, value string) string {
+	if s.secret {
+		return redact(value)
+	}
+	return value
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// parseInto sets the field to the parsed value
+func parseInto(field reflect.Value, value string) error {
+	if field.Addr().Type().Implements(textUnmarshalerType) {
+		if value == "" {
+			return nil
+		}
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	switch {
+	case field.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("not a duration such as 30s or 5m")
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		field.SetInt(int64(i))
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		// A comma separated list
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case field.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("not a number")
+		}
+		field.SetFloat(f)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("not a boolean")
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// readFile reads a YAML or TOML file, chosen by extension, into values by
+// dotted key
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := make(map[string]interface{})
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("unsupported config file %s, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", tree, values)
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]interface{}, values map[string]string) {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch value := value.(type) {
+		case map[string]interface{}:
+			flatten(key, value, values)
+		case []interface{}:
+			// Lists are given as comma separated values elsewhere
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+}
+
+func unknownKeys(values map[string]string, known map[string]bool) []string {
+	var unknown []string
+	for key := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
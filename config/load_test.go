@@ -0,0 +1,196 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile stores a config file in a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c, err := Load("", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, ":8080", c.Addr())
+	assert.Equal(t, 30*time.Second, c.ShutdownTimeout)
+	assert.Equal(t, 25, c.DBMaxOpenConns)
+	assert.Equal(t, 0, c.RedisDB)
+	assert.Equal(t, MigrateOnBootAuto, c.MigrateOnBoot)
+	assert.Equal(t, RateLimitPolicy{Limit: 300, Window: time.Minute, Algorithm: "token_bucket", KeyBy: "ip"}, c.RateLimits["read"])
+	assert.Equal(t, RateLimitPolicy{Limit: 50, Window: time.Minute, Algorithm: "token_bucket", KeyBy: "ip"}, c.RateLimits["write"])
+	assert.Equal(t, RateLimitPolicy{Limit: 600, Window: time.Minute, Algorithm: "token_bucket", KeyBy: "ip"}, c.RateLimits["auth"])
+
+	// Requests are limited by IP before the user is known
+	c, err = Load("", map[string]string{"rate_limit.key": "user"})
+	require.NoError(t, err)
+	assert.Equal(t, "user", c.RateLimits["read"].KeyBy)
+	assert.Equal(t, "ip", c.RateLimits["auth"].KeyBy)
+}
+
+func TestLoadLayers(t *testing.T) {
+	yamlFile := writeFile(t, "app.yaml", `
+server:
+  port: 9000
+  shutdown_timeout: 10s
+redis:
+  db: 2
+  port: 6380
+rate_limit:
+  write: 10/1s
+`)
+	t.Setenv("REDIS_DB", "3")
+	t.Setenv("SHUTDOWN_TIMEOUT", "20s")
+
+	c, err := Load(yamlFile, map[string]string{"redis.db": "4"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 9000, c.Port)
+	assert.Equal(t, 20*time.Second, c.ShutdownTimeout)
+	assert.Equal(t, 4, c.RedisDB)
+	assert.Equal(t, 6380, c.RedisPort)
+	assert.Equal(t, 10, c.RateLimits["write"].Limit)
+	assert.Equal(t, time.Second, c.RateLimits["write"].Window)
+
+	sources := make(map[string]string)
+	for _, s := range c.Settings() {
+		sources[s.Name] = s.Source
+	}
+	assert.Equal(t, SourceFile, sources["server.port"])
+	assert.Equal(t, SourceEnv, sources["server.shutdown_timeout"])
+	assert.Equal(t, SourceFlag, sources["redis.db"])
+	assert.Equal(t, SourceDefault, sources["db.host"])
+
+	tomlFile := writeFile(t, "app.toml", "[db]\nhost = \"db.internal\"\nmax_open_conns = 50\n")
+	c, err = Load(tomlFile, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "db.internal", c.DBHost)
+	assert.Equal(t, 50, c.DBMaxOpenConns)
+}
+
+func TestLoadValidation(t *testing.T) {
+	file := writeFile(t, "app.yaml", "server:\n  prot: 9000\n")
+	t.Setenv("APP_PORT", "70000")
+	t.Setenv("CACHE_TTL", "soon")
+	t.Setenv("DB_MAX_IDLE_CONNS", "30")
+	t.Setenv("DB_PASSWORD", "hunter2")
+
+	_, err := Load(file, map[string]string{"log.level": "loud", "db.password": "x"})
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.ElementsMatch(t, []string{
+		`cache.ttl (CACHE_TTL): invalid value "soon" from env: not a duration such as 30s or 5m`,
+		"server.prot: unknown setting in " + file,
+		"server.port (APP_PORT): must be between 1 and 65535, got 70000",
+		"db.max_idle_conns (DB_MAX_IDLE_CONNS): must be between 0 and db.max_open_conns (25), got 30",
+		`log.level (LOG_LEVEL): "loud" must be one of ["" "debug" "info" "warn" "error"]`,
+		"db.password (DB_PASSWORD): is secret, set it in a file or the environment instead of a flag",
+	}, validationErr.Problems)
+
+	_, err = Load(writeFile(t, "app.json", "{}"), nil)
+	assert.ErrorContains(t, err, "unsupported config file")
+}
+
+func TestSettingsRedactSecrets(t *testing.T) {
+	t.Setenv("JWT_SECRET", "super-secret")
+	c, err := Load("", nil)
+	require.NoError(t, err)
+
+	values := make(map[string]string)
+	for _, s := range c.Settings() {
+		values[s.Name] = s.Value
+	}
+	assert.Equal(t, "[REDACTED]", values["auth.jwt_secret"])
+	assert.Equal(t, "[REDACTED]", values["db.password"])
+	assert.Equal(t, "", values["redis.password"])
+	assert.Equal(t, "300/1m0s", values["rate_limit.read"])
+	assert.Equal(t, "8080", values["server.port"])
+}
+
+func TestLoadDrivers(t *testing.T) {
+	c, err := Load("", map[string]string{"db.driver": "postgres"})
+	require.NoError(t, err)
+	assert.Equal(t, 5432, c.DBPort)
+
+	c, err = Load("", map[string]string{"db.driver": "postgres", "db.port": "6432"})
+	require.NoError(t, err)
+	assert.Equal(t, 6432, c.DBPort)
+
+	// SQLite only needs the file
+	_, err = Load("", map[string]string{"db.driver": "sqlite", "db.host": "", "db.name": "app.db"})
+	assert.NoError(t, err)
+
+	_, err = Load("", map[string]string{"db.driver": "oracle"})
+	assert.ErrorContains(t, err, `db.driver (DB_DRIVER): "oracle" must be one of`)
+	_, err = Load("", map[string]string{"db.driver": "postgres", "db.ssl_mode": "on"})
+	assert.ErrorContains(t, err, "db.ssl_mode (DB_SSL_MODE)")
+}
+
+func TestLoadReplicas(t *testing.T) {
+	yamlFile := writeFile(t, "app.yaml", `
+db:
+  replicas:
+    - app:secret@tcp(replica-1:3306)/todos
+    - app:secret@tcp(replica-2:3306)/todos
+`)
+	c, err := Load(yamlFile, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app:secret@tcp(replica-1:3306)/todos", "app:secret@tcp(replica-2:3306)/todos"}, c.DBReplicas)
+
+	t.Setenv("DB_REPLICAS", "replica-1, replica-2,")
+	c, err = Load("", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"replica-1", "replica-2"}, c.DBReplicas)
+	for _, s := range c.Settings() {
+		if s.Name == "db.replicas" {
+			assert.Equal(t, "[REDACTED]", s.Value)
+		}
+	}
+
+	_, err = Load("", map[string]string{"db.driver": "sqlite"})
+	assert.ErrorContains(t, err, "db.replicas (DB_REPLICAS): aren't supported with sqlite")
+}
+
+func TestLoadCache(t *testing.T) {
+	c, err := Load("", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, c.CacheNegativeTTL)
+	assert.Equal(t, 0.1, c.CacheTTLJitter)
+	assert.Equal(t, 1.0, c.CacheEarlyRefreshBeta)
+	assert.Equal(t, 5*time.Second, c.CacheLoadTimeout)
+
+	t.Setenv("CACHE_TTL_JITTER", "0.25")
+	c, err = Load("", map[string]string{"cache.early_refresh_beta": "0"})
+	require.NoError(t, err)
+	assert.Equal(t, 0.25, c.CacheTTLJitter)
+	assert.Equal(t, 0.0, c.CacheEarlyRefreshBeta)
+
+	_, err = Load("", map[string]string{"cache.ttl_jitter": "1", "cache.early_refresh_beta": "-1"})
+	assert.ErrorContains(t, err, "cache.ttl_jitter (CACHE_TTL_JITTER)")
+	assert.ErrorContains(t, err, "cache.early_refresh_beta (CACHE_EARLY_REFRESH_BETA)")
+
+	_, err = Load("", map[string]string{"cache.ttl_jitter": "lots"})
+	assert.ErrorContains(t, err, "cache.ttl_jitter")
+}
+
+func TestRegisterFlagsSkipsSecrets(t *testing.T) {
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	RegisterFlags(fs)
+
+	assert.NotNil(t, fs.Lookup("server.port"))
+	for _, name := range []string{"db.password", "db.replicas", "redis.password", "auth.jwt_secret"} {
+		assert.Nil(t, fs.Lookup(name), name)
+	}
+}
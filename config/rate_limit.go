@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitPolicy configures one named rate limit policy
+type RateLimitPolicy struct {
+	// Limit is the number of requests allowed per Window
+	Limit  int
+	Window time.Duration
+	// Algorithm is "token_bucket" or "sliding_window"
+	Algorithm string
+	// KeyBy is what requests are counted against: "ip", "api_key" or "user"
+	KeyBy string
+}
+
+// Rate is a number of requests per window, written as "<limit>/<window>", e.g. "50/1m"
+type Rate struct {
+	Limit  int
+	Window time.Duration
+}
+
+func (r *Rate) UnmarshalText(text []byte) error {
+	limitPart, windowPart, ok := strings.Cut(string(text), "/")
+	if !ok {
+		return fmt.Errorf("expected <limit>/<window>")
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(limitPart))
+	if err != nil || limit <= 0 {
+		return fmt.Errorf("limit must be a positive integer")
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(windowPart))
+	if err != nil || window <= 0 {
+		return fmt.Errorf("window must be a positive duration")
+	}
+
+	r.Limit = limit
+	r.Window = window
+	return nil
+}
+
+func (r Rate) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d/%s", r.Limit, r.Window)), nil
+}
+
+// rateLimits builds the policies from the rate limit settings
+func (c *Config) rateLimits() map[string]RateLimitPolicy {
+	policy := func(rate Rate) RateLimitPolicy {
+		return RateLimitPolicy{Limit: rate.Limit, Window: rate.Window, Algorithm: c.RateLimitAlgorithm, KeyBy: c.RateLimitKey}
+	}
+	auth := policy(c.RateLimitAuth)
+	// The caller isn't known yet
+	auth.KeyBy = "ip"
+	return map[string]RateLimitPolicy{
+		"auth":  auth,
+		"read":  policy(c.RateLimitRead),
+		"write": policy(c.RateLimitWrite),
+	}
+}
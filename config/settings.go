@@ -0,0 +1,54 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"strings"
+)
+
+// redacted replaces secret values in Settings
+const redacted = "[REDACTED]"
+
+// Setting is one configuration value and where it came from
+type Setting struct {
+	Name   string
+	Value  string
+	Source string
+}
+
+// Settings lists the effective configuration by key, with secrets redacted
+func (c *Config) Settings() []Setting {
+	var settings []Setting
+	for _, s := range c.settings() {
+		value := format(s.value.Interface())
+		if s.secret {
+			value = redact(value)
+		}
+		source := c.sources[s.key]
+		if source == "" {
+			source = SourceDefault
+		}
+		settings = append(settings, Setting{Name: s.key, Value: value, Source: source})
+	}
+	return settings
+}
+
+func format(value interface{}) string {
+	if list, ok := value.([]string); ok {
+		return strings.Join(list, ",")
+	}
+	if marshaler, ok := value.(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
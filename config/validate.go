@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// validate returns a problem for every setting outside its allowed values,
+// skipping the settings that couldn't be parsed
+func (c *Config) validate(invalid map[string]bool) []string {
+	names := make(map[string]string)
+	for _, s := range c.settings() {
+		names[s.key] = s.describe()
+	}
+
+	var problems []string
+	check := func(ok bool, key, format string, args ...interface{}) {
+		if !ok && !invalid[key] {
+			problems = append(problems, names[key]+": "+fmt.Sprintf(format, args...))
+		}
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		check(slices.Contains(allowed, value), key, "%q must be one of %q", value, allowed)
+	}
+	positive := func(key string, d time.Duration) {
+		check(d > 0, key, "must be positive, got %s", d)
+	}
+
+	check(c.Port > 0 && c.Port < 65536, "server.port", "must be between 1 and 65535, got %d", c.Port)
+	positive("server.shutdown_timeout", c.ShutdownTimeout)
+	check(c.ShutdownDelay >= 0, "server.shutdown_delay", "must not be negative")
+	positive("server.health_check_timeout", c.HealthCheckTimeout)
+
+	oneOf("db.driver", c.DBDriver, DriverMySQL, DriverPostgres, DriverSQLite)
+	if c.DBDriver != DriverSQLite {
+		check(c.DBHost != "", "db.host", "is required")
+		check(c.DBPort > 0 && c.DBPort < 65536, "db.port", "must be between 1 and 65535, got %d", c.DBPort)
+	}
+	check(c.DBName != "", "db.name", "is required")
+	if c.DBDriver == DriverPostgres {
+		oneOf("db.ssl_mode", c.DBSSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	}
+	check(c.DBMaxOpenConns > 0, "db.max_open_conns", "must be positive, got %d", c.DBMaxOpenConns)
+	check(c.DBMaxIdleConns >= 0 && c.DBMaxIdleConns <= c.DBMaxOpenConns, "db.max_idle_conns",
+		"must be between 0 and db.max_open_conns (%d), got %d", c.DBMaxOpenConns, c.DBMaxIdleConns)
+	check(c.DBConnMaxLifetime >= 0, "db.conn_max_lifetime", "must not be negative")
+	check(c.DBConnMaxIdleTime >= 0, "db.conn_max_idle_time", "must not be negative")
+	positive("db.connect_timeout", c.DBConnectTimeout)
+	check(c.DBDriver != DriverSQLite || len(c.DBReplicas) == 0, "db.replicas", "aren't supported with sqlite")
+	positive("db.replica_check_interval", c.DBReplicaCheckInterval)
+	check(c.DBReadYourWritesWindow >= 0, "db.read_your_writes_window", "must not be negative")
+	oneOf("db.migrate_on_boot", c.MigrateOnBoot, MigrateOnBootOff, MigrateOnBootAuto, MigrateOnBootVerifyOnly)
+
+	check(c.RedisHost != "", "redis.host", "is required")
+	check(c.RedisPort > 0 && c.RedisPort < 65536, "redis.port", "must be between 1 and 65535, got %d", c.RedisPort)
+	check(c.RedisDB >= 0, "redis.db", "must not be negative, got %d", c.RedisDB)
+	check(c.RedisPoolSize >= 0, "redis.pool_size", "must not be negative, got %d", c.RedisPoolSize)
+	check(c.RedisMinIdleConns >= 0, "redis.min_idle_conns", "must not be negative, got %d", c.RedisMinIdleConns)
+	positive("redis.dial_timeout", c.RedisDialTimeout)
+	positive("redis.timeout", c.RedisTimeout)
+	positive("redis.connect_timeout", c.RedisConnectTimeout)
+	positive("redis.retry_interval", c.RedisRetryInterval)
+
+	positive("cache.ttl", c.CacheTTL)
+	positive("cache.list_ttl", c.CacheListTTL)
+	positive("cache.item_ttl", c.CacheItemTTL)
+	positive("cache.negative_ttl", c.CacheNegativeTTL)
+	check(c.CacheTTLJitter >= 0 && c.CacheTTLJitter < 1, "cache.ttl_jitter", "must be at least 0 and less than 1, got %g", c.CacheTTLJitter)
+	positive("cache.load_timeout", c.CacheLoadTimeout)
+	check(c.CacheEarlyRefreshBeta >= 0, "cache.early_refresh_beta", "must not be negative, got %g", c.CacheEarlyRefreshBeta)
+
+	check(c.RateLimitRead.Limit > 0, "rate_limit.read", "is required")
+	check(c.RateLimitWrite.Limit > 0, "rate_limit.write", "is required")
+	check(c.RateLimitAuth.Limit > 0, "rate_limit.auth", "is required")
+	oneOf("rate_limit.algorithm", c.RateLimitAlgorithm, "token_bucket", "sliding_window")
+	oneOf("rate_limit.key", c.RateLimitKey, "ip", "api_key", "user")
+	check(c.RateLimitMaxKeys > 0, "rate_limit.max_keys", "must be positive, got %d", c.RateLimitMaxKeys)
+	positive("rate_limit.janitor_interval", c.RateLimitJanitorInterval)
+
+	oneOf("log.level", c.LogLevel, "", "debug", "info", "warn", "error")
+	oneOf("log.format", c.LogFormat, "", "console", "json")
+
+	return problems
+}
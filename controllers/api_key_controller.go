@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"tuhuynh.com/go-ioc-gin-example/dto"
+	"tuhuynh.com/go-ioc-gin-example/services"
+)
+
+// APIKeyController handles the admin endpoints for minting, rotating and
+// revoking API keys
+type APIKeyController struct {
+	Component struct{}
+	Service   services.APIKeyService `autowired:"true"`
+}
+
+func (c *APIKeyController) CreateAPIKey(ctx *gin.Context) {
+	var req dto.CreateAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.Error(dto.BindError(err))
+		return
+	}
+
+	key, secret, err := c.Service.Mint(ctx.Request.Context(), req.Name, req.Subject, req.Scopes)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, dto.NewMintedAPIKeyResponse(key, secret))
+}
+
+func (c *APIKeyController) ListAPIKeys(ctx *gin.Context) {
+	keys, err := c.Service.List(ctx.Request.Context())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewAPIKeyListResponse(keys))
+}
+
+func (c *APIKeyController) RotateAPIKey(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	key, secret, err := c.Service.Rotate(ctx.Request.Context(), id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewMintedAPIKeyResponse(key, secret))
+}
+
+func (c *APIKeyController) RevokeAPIKey(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := c.Service.Revoke(ctx.Request.Context(), id); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
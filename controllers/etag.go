@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+)
+
+// etag renders a todo version as a strong entity tag
+func etag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatch is what the If-Match header requires of the todo
+type ifMatch struct {
+	// any is set by "*", which every existing todo matches
+	any bool
+	// versions are those of the listed entity tags that can match
+	versions []int
+}
+
+// parseIfMatch reads the If-Match header, "*" or a list of entity tags
+func parseIfMatch(ctx *gin.Context) (ifMatch, error) {
+	var m ifMatch
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	switch header {
+	case "":
+		return m, nil
+	case "*":
+		m.any = true
+		return m, nil
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		// If-Match uses strong comparison, so a weak tag can never match
+		weak := strings.HasPrefix(tag, "W/")
+		opaque := strings.TrimPrefix(tag, "W/")
+		if len(opaque) < 2 || !strings.HasPrefix(opaque, `"`) || !strings.HasSuffix(opaque, `"`) {
+			return m, apperrors.Validation(`If-Match must be "*" or a list of entity tags such as %s`, etag(1))
+		}
+
+		// Tags that aren't versions are valid, they just never match
+		version, err := strconv.Atoi(strings.Trim(opaque, `"`))
+		if !weak && err == nil && version > 0 {
+			m.versions = append(m.versions, version)
+		}
+	}
+	if len(m.versions) == 0 {
+		return m, apperrors.PreconditionFailed("no entity tag in If-Match can match, weak tags never do")
+	}
+	return m, nil
+}
+
+// conditionally runs a version checked write as If-Match requires: once with
+// version 0, meaning unchecked, without the header or with "*", and otherwise
+// once per listed version until the todo has one of them. With "*" a missing
+// todo fails the precondition.
+func conditionally(ctx *gin.Context, write func(version int) error) error {
+	m, err := parseIfMatch(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(m.versions) == 0 {
+		err := write(0)
+		if m.any && errors.Is(err, apperrors.ErrNotFound) {
+			return apperrors.PreconditionFailed("If-Match is * but the todo doesn't exist")
+		}
+		return err
+	}
+
+	for _, version := range m.versions {
+		if err = write(version); !errors.Is(err, apperrors.ErrPreconditionFailed) {
+			return err
+		}
+	}
+	return err
+}
+
+// ifNoneMatch reports whether the If-None-Match header matches the current entity tag
+func ifNoneMatch(ctx *gin.Context, current string) bool {
+	header := ctx.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		// If-None-Match uses weak comparison
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
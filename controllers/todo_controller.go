@@ -3,46 +3,91 @@ package controllers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/dto"
 	"tuhuynh.com/go-ioc-gin-example/entities"
-	"tuhuynh.com/go-ioc-gin-example/security"
+	"tuhuynh.com/go-ioc-gin-example/repositories"
 	"tuhuynh.com/go-ioc-gin-example/services"
 )
 
+// TodoController handles the todo endpoints. Failures are recorded with
+// ctx.Error and rendered as problem responses by middleware.ErrorHandler.
+// Rate limiting is applied per route by the router.
 type TodoController struct {
-	Component   struct{}
-	Service     services.TodoService  `autowired:"true"`
-	RateLimiter *security.RateLimiter `autowired:"true"`
+	Component struct{}
+	Service   services.TodoService `autowired:"true"`
 }
 
 func (c *TodoController) ListTodos(ctx *gin.Context) {
-	todos, err := c.Service.List(ctx.Request.Context())
+	query, err := parseListQuery(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, todos)
+	page, err := c.Service.List(ctx.Request.Context(), query)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewTodoListResponse(page))
 }
 
-func (c *TodoController) CreateTodo(ctx *gin.Context) {
-	ip := ctx.ClientIP()
+// parseListQuery reads the limit, cursor, completed, q and sort query parameters
+func parseListQuery(ctx *gin.Context) (repositories.TodoListQuery, error) {
+	query := repositories.TodoListQuery{
+		Cursor: ctx.Query("cursor"),
+		Search: strings.TrimSpace(ctx.Query("q")),
+		Sort:   repositories.TodoSort(ctx.Query("sort")),
+	}
 
-	if !c.RateLimiter.AllowRequest(ip) {
-		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded. Try again later."})
-		return
+	if limit := ctx.Query("limit"); limit != "" {
+		limitInt, err := strconv.Atoi(limit)
+		if err != nil || limitInt <= 0 {
+			return query, apperrors.Validation("limit must be a positive integer")
+		}
+		query.Limit = limitInt
 	}
 
-	var todo entities.Todo
-	if err := ctx.ShouldBindJSON(&todo); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if completed := ctx.Query("completed"); completed != "" {
+		completedBool, err := strconv.ParseBool(completed)
+		if err != nil {
+			return query, apperrors.Validation("completed must be true or false")
+		}
+		query.Completed = &completedBool
+	}
+
+	return query.Normalize()
+}
+
+// parseID reads the :id path parameter
+func parseID(ctx *gin.Context) (int, error) {
+	id := ctx.Param("id")
+	if id == "" {
+		return 0, apperrors.Validation("id is required")
+	}
+
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, apperrors.Validation("invalid id format")
+	}
+	return idInt, nil
+}
+
+func (c *TodoController) CreateTodo(ctx *gin.Context) {
+	var req dto.CreateTodoRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.Error(dto.BindError(err))
 		return
 	}
 
-	err := c.Service.Create(ctx.Request.Context(), todo)
+	err := c.Service.Create(ctx.Request.Context(), req.ToEntity())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
@@ -50,63 +95,111 @@ func (c *TodoController) CreateTodo(ctx *gin.Context) {
 }
 
 func (c *TodoController) GetTodo(ctx *gin.Context) {
-	id := ctx.Param("id")
-	if id == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+	id, err := parseID(ctx)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	idInt, err := strconv.Atoi(id)
+	todo, err := c.Service.Get(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+		ctx.Error(err)
 		return
 	}
-	todo, err := c.Service.Get(ctx.Request.Context(), idInt)
-	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+
+	tag := etag(todo.Version)
+	ctx.Header("ETag", tag)
+	if ifNoneMatch(ctx, tag) {
+		ctx.Status(http.StatusNotModified)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, todo)
+	ctx.JSON(http.StatusOK, dto.NewTodoResponse(todo))
 }
 
 func (c *TodoController) UpdateTodo(ctx *gin.Context) {
-	id := ctx.Param("id")
-	if id == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+	id, err := parseID(ctx)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	var todo entities.Todo
-	if err := ctx.ShouldBindJSON(&todo); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if _, err := parseIfMatch(ctx); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	var req dto.UpdateTodoRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.Error(dto.BindError(err))
 		return
 	}
 
-	err := c.Service.Update(ctx.Request.Context(), todo)
+	todo := req.ToEntity(id)
+	var updated entities.Todo
+	err = conditionally(ctx, func(version int) error {
+		todo.Version = version
+		var err error
+		updated, err = c.Service.Update(ctx.Request.Context(), todo)
+		return err
+	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Todo updated successfully"})
+	ctx.Header("ETag", etag(updated.Version))
+	ctx.JSON(http.StatusOK, dto.NewTodoResponse(updated))
 }
 
-func (c *TodoController) DeleteTodo(ctx *gin.Context) {
-	id := ctx.Param("id")
-	if id == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+// PatchTodo applies a JSON Merge Patch to the todo and returns the result
+func (c *TodoController) PatchTodo(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	idInt, err := strconv.Atoi(id)
+	if _, err := parseIfMatch(ctx); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	var req dto.PatchTodoRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.Error(dto.BindError(err))
+		return
+	}
+
+	patch := req.ToPatch()
+	var todo entities.Todo
+	err = conditionally(ctx, func(version int) error {
+		patch.Version = version
+		var err error
+		todo, err = c.Service.Patch(ctx.Request.Context(), id, patch)
+		return err
+	})
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+		ctx.Error(err)
 		return
 	}
-	err = c.Service.Delete(ctx.Request.Context(), idInt)
+
+	ctx.Header("ETag", etag(todo.Version))
+	ctx.JSON(http.StatusOK, dto.NewTodoResponse(todo))
+}
+
+func (c *TodoController) DeleteTodo(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = conditionally(ctx, func(version int) error {
+		return c.Service.Delete(ctx.Request.Context(), id, version)
+	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
@@ -7,13 +7,18 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/dto"
 	"tuhuynh.com/go-ioc-gin-example/entities"
-	"tuhuynh.com/go-ioc-gin-example/security"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+	"tuhuynh.com/go-ioc-gin-example/middleware"
+	"tuhuynh.com/go-ioc-gin-example/repositories"
 )
 
 // MockTodoService is a mock implementation of TodoService
@@ -21,12 +26,9 @@ type MockTodoService struct {
 	mock.Mock
 }
 
-func (m *MockTodoService) List(ctx context.Context) ([]entities.Todo, error) {
-	args := m.Called(ctx)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).([]entities.Todo), args.Error(1)
+func (m *MockTodoService) List(ctx context.Context, query repositories.TodoListQuery) (repositories.TodoPage, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(repositories.TodoPage), args.Error(1)
 }
 
 func (m *MockTodoService) Create(ctx context.Context, todo entities.Todo) error {
@@ -39,13 +41,18 @@ func (m *MockTodoService) Get(ctx context.Context, id int) (entities.Todo, error
 	return args.Get(0).(entities.Todo), args.Error(1)
 }
 
-func (m *MockTodoService) Update(ctx context.Context, todo entities.Todo) error {
+func (m *MockTodoService) Update(ctx context.Context, todo entities.Todo) (entities.Todo, error) {
 	args := m.Called(ctx, todo)
-	return args.Error(0)
+	return args.Get(0).(entities.Todo), args.Error(1)
 }
 
-func (m *MockTodoService) Delete(ctx context.Context, id int) error {
-	args := m.Called(ctx, id)
+func (m *MockTodoService) Patch(ctx context.Context, id int, patch repositories.TodoPatch) (entities.Todo, error) {
+	args := m.Called(ctx, id, patch)
+	return args.Get(0).(entities.Todo), args.Error(1)
+}
+
+func (m *MockTodoService) Delete(ctx context.Context, id int, version int) error {
+	args := m.Called(ctx, id, version)
 	return args.Error(0)
 }
 
@@ -54,49 +61,104 @@ func setupTest() (*gin.Engine, *MockTodoService) {
 	r := gin.New()
 	mockService := new(MockTodoService)
 
-	rateLimiter := &security.RateLimiter{}
-	rateLimiter.PostConstruct()
+	errorHandler := &middleware.ErrorHandler{Log: logger.NewNopLogger()}
+	r.Use(errorHandler.Handle)
 
 	controller := &TodoController{
-		Service:     mockService,
-		RateLimiter: rateLimiter,
+		Service: mockService,
 	}
 
 	r.GET("/todos", controller.ListTodos)
 	r.POST("/todos", controller.CreateTodo)
 	r.GET("/todos/:id", controller.GetTodo)
 	r.PUT("/todos/:id", controller.UpdateTodo)
+	r.PATCH("/todos/:id", controller.PatchTodo)
 	r.DELETE("/todos/:id", controller.DeleteTodo)
 
 	return r, mockService
 }
 
+// decodeProblem asserts the response is a problem+json body and decodes it
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) middleware.Problem {
+	assert.Equal(t, middleware.ProblemContentType, w.Header().Get("Content-Type"))
+	var problem middleware.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, w.Code, problem.Status)
+	return problem
+}
+
 func TestListTodos(t *testing.T) {
 	r, mockService := setupTest()
 
 	t.Run("success", func(t *testing.T) {
-		todos := []entities.Todo{{ID: 1, Title: "Test Todo", Completed: false}}
-		mockService.On("List", mock.Anything).Return(todos, nil).Once()
+		page := repositories.TodoPage{
+			Todos:      []entities.Todo{{ID: 1, Title: "Test Todo", Completed: false}},
+			NextCursor: "next",
+		}
+		query := repositories.TodoListQuery{Limit: repositories.DefaultListLimit, Sort: repositories.SortCreatedAtAsc}
+		mockService.On("List", mock.Anything, query).Return(page, nil).Once()
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, "/todos", nil)
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
-		var response []entities.Todo
+		var response dto.TodoListResponse
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, todos, response)
+		assert.Equal(t, dto.NewTodoListResponse(page), response)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		completed := true
+		query := repositories.TodoListQuery{
+			Limit:     5,
+			Completed: &completed,
+			Search:    "milk",
+			Sort:      repositories.SortUpdatedAtDesc,
+		}
+		mockService.On("List", mock.Anything, query).Return(repositories.TodoPage{Todos: []entities.Todo{}}, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/todos?limit=5&completed=true&q=milk&sort=-updated_at", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for _, params := range []string{"limit=abc", "completed=maybe", "sort=title", "cursor=garbage"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos?"+params, nil)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, params)
+			assert.Equal(t, apperrors.CodeValidation, decodeProblem(t, w).Code)
+		}
 	})
 
 	t.Run("error", func(t *testing.T) {
-		mockService.On("List", mock.Anything).Return(nil, errors.New("database error")).Once()
+		mockService.On("List", mock.Anything, mock.Anything).Return(repositories.TodoPage{}, errors.New("database error")).Once()
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, "/todos", nil)
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		problem := decodeProblem(t, w)
+		assert.Equal(t, apperrors.CodeInternal, problem.Code)
+		assert.NotContains(t, problem.Detail, "database error")
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		mockService.On("List", mock.Anything, mock.Anything).Return(repositories.TodoPage{}, apperrors.Unavailable(errors.New("dial tcp"), "database unavailable")).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/todos", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, apperrors.CodeUnavailable, decodeProblem(t, w).Code)
 	})
 }
 
@@ -107,7 +169,7 @@ func TestCreateTodo(t *testing.T) {
 		todo := entities.Todo{Title: "New Todo", Completed: false}
 		mockService.On("Create", mock.Anything, todo).Return(nil).Once()
 
-		todoJSON, _ := json.Marshal(todo)
+		todoJSON, _ := json.Marshal(dto.CreateTodoRequest{Title: "  New Todo  "})
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(todoJSON))
 		req.Header.Set("Content-Type", "application/json")
@@ -124,6 +186,38 @@ func TestCreateTodo(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
+
+	t.Run("server managed fields are ignored", func(t *testing.T) {
+		todo := entities.Todo{Title: "New Todo"}
+		mockService.On("Create", mock.Anything, todo).Return(nil).Once()
+
+		body := `{"id": 42, "title": "New Todo", "CreatedAt": "2020-01-01T00:00:00Z", "DeletedAt": "2020-01-01T00:00:00Z"}`
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/todos", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("validation errors", func(t *testing.T) {
+		body, _ := json.Marshal(dto.CreateTodoRequest{
+			Title:       "   ",
+			Description: strings.Repeat("x", 2001),
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		problem := decodeProblem(t, w)
+		assert.Equal(t, apperrors.CodeValidation, problem.Code)
+		assert.ElementsMatch(t, []apperrors.FieldError{
+			{Field: "title", Message: "must not be blank"},
+			{Field: "description", Message: "must be at most 2000 characters"},
+		}, problem.Errors)
+	})
 }
 
 func TestGetTodo(t *testing.T) {
@@ -138,20 +232,24 @@ func TestGetTodo(t *testing.T) {
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
-		var response entities.Todo
+		var response dto.TodoResponse
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, todo, response)
+		assert.Equal(t, dto.NewTodoResponse(todo), response)
 	})
 
 	t.Run("not found", func(t *testing.T) {
-		mockService.On("Get", mock.Anything, 999).Return(entities.Todo{}, errors.New("not found")).Once()
+		mockService.On("Get", mock.Anything, 999).Return(entities.Todo{}, apperrors.NotFound("todo 999 not found")).Once()
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, "/todos/999", nil)
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusNotFound, w.Code)
+		problem := decodeProblem(t, w)
+		assert.Equal(t, apperrors.CodeNotFound, problem.Code)
+		assert.Equal(t, "todo 999 not found", problem.Detail)
+		assert.Equal(t, "/todos/999", problem.Instance)
 	})
 }
 
@@ -160,15 +258,17 @@ func TestUpdateTodo(t *testing.T) {
 
 	t.Run("success", func(t *testing.T) {
 		todo := entities.Todo{ID: 1, Title: "Updated Todo", Completed: true}
-		mockService.On("Update", mock.Anything, todo).Return(nil).Once()
+		mockService.On("Update", mock.Anything, todo).Return(entities.Todo{ID: 1, Title: "Updated Todo", Completed: true, Version: 2}, nil).Once()
 
-		todoJSON, _ := json.Marshal(todo)
+		// The body id is ignored, the path id is authoritative
+		todoJSON, _ := json.Marshal(map[string]interface{}{"id": 7, "title": "Updated Todo", "completed": true})
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodPut, "/todos/1", bytes.NewBuffer(todoJSON))
 		req.Header.Set("Content-Type", "application/json")
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
 	})
 
 	t.Run("invalid json", func(t *testing.T) {
@@ -179,13 +279,207 @@ func TestUpdateTodo(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockService.On("Update", mock.Anything, mock.Anything).Return(entities.Todo{}, apperrors.NotFound("todo 999 not found")).Once()
+
+		todoJSON, _ := json.Marshal(dto.UpdateTodoRequest{Title: "Updated Todo"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPut, "/todos/999", bytes.NewBuffer(todoJSON))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestPatchTodo(t *testing.T) {
+	r, mockService := setupTest()
+
+	t.Run("only supplied fields", func(t *testing.T) {
+		completed := true
+		patch := repositories.TodoPatch{Completed: &completed}
+		todo := entities.Todo{ID: 1, Title: "Existing", Completed: true}
+		mockService.On("Patch", mock.Anything, 1, patch).Return(todo, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPatch, "/todos/1", bytes.NewBufferString(`{"completed": true}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response dto.TodoResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, dto.NewTodoResponse(todo), response)
+	})
+
+	t.Run("null clears description", func(t *testing.T) {
+		empty := ""
+		patch := repositories.TodoPatch{Description: &empty}
+		mockService.On("Patch", mock.Anything, 1, patch).Return(entities.Todo{ID: 1, Title: "Existing"}, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPatch, "/todos/1", bytes.NewBufferString(`{"description": null}`))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("null title is rejected", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPatch, "/todos/1", bytes.NewBufferString(`{"title": null}`))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, []apperrors.FieldError{{Field: "title", Message: "must not be blank"}}, decodeProblem(t, w).Errors)
+	})
+
+	t.Run("not an object", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPatch, "/todos/1", bytes.NewBufferString(`null`))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockService.On("Patch", mock.Anything, 999, mock.Anything).Return(entities.Todo{}, apperrors.NotFound("todo 999 not found")).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPatch, "/todos/999", bytes.NewBufferString(`{"title": "x"}`))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestConditionalRequests(t *testing.T) {
+	r, mockService := setupTest()
+	todo := entities.Todo{ID: 1, Title: "Test Todo", Version: 3}
+
+	t.Run("get returns etag", func(t *testing.T) {
+		mockService.On("Get", mock.Anything, 1).Return(todo, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/todos/1", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("if-none-match returns not modified", func(t *testing.T) {
+		mockService.On("Get", mock.Anything, 1).Return(todo, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/todos/1", nil)
+		req.Header.Set("If-None-Match", `"2", W/"3"`)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.Bytes())
+	})
+
+	t.Run("if-match is passed through", func(t *testing.T) {
+		completed := true
+		mockService.On("Patch", mock.Anything, 1, repositories.TodoPatch{Completed: &completed, Version: 3}).
+			Return(entities.Todo{ID: 1, Title: "Test Todo", Completed: true, Version: 4}, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPatch, "/todos/1", bytes.NewBufferString(`{"completed": true}`))
+		req.Header.Set("If-Match", `"3"`)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("stale if-match", func(t *testing.T) {
+		mockService.On("Delete", mock.Anything, 1, 2).Return(apperrors.PreconditionFailed("todo 1 has been modified")).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, "/todos/1", nil)
+		req.Header.Set("If-Match", `"2"`)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		assert.Equal(t, apperrors.CodePreconditionFailed, decodeProblem(t, w).Code)
+	})
+
+	t.Run("weak if-match", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, "/todos/1", nil)
+		req.Header.Set("If-Match", `W/"3"`)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("if-match list", func(t *testing.T) {
+		mockService.On("Delete", mock.Anything, 1, 3).Return(apperrors.PreconditionFailed("todo 1 has been modified")).Once()
+		mockService.On("Delete", mock.Anything, 1, 4).Return(nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, "/todos/1", nil)
+		req.Header.Set("If-Match", `"3", W/"5", "4"`)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		// Every listed version was tried in turn
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("if-match list without a match", func(t *testing.T) {
+		mockService.On("Delete", mock.Anything, 1, 1).Return(apperrors.PreconditionFailed("todo 1 has been modified")).Once()
+		mockService.On("Delete", mock.Anything, 1, 2).Return(apperrors.PreconditionFailed("todo 1 has been modified")).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, "/todos/1", nil)
+		req.Header.Set("If-Match", `"1","2", "other"`)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("if-match any", func(t *testing.T) {
+		completed := true
+		mockService.On("Patch", mock.Anything, 1, repositories.TodoPatch{Completed: &completed}).
+			Return(entities.Todo{ID: 1, Title: "Test Todo", Completed: true, Version: 4}, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPatch, "/todos/1", bytes.NewBufferString(`{"completed": true}`))
+		req.Header.Set("If-Match", "*")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("if-match any missing", func(t *testing.T) {
+		mockService.On("Delete", mock.Anything, 2, 0).Return(apperrors.NotFound("todo 2 not found")).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, "/todos/2", nil)
+		req.Header.Set("If-Match", "*")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("malformed if-match", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, "/todos/1", nil)
+		req.Header.Set("If-Match", "3")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
 }
 
 func TestDeleteTodo(t *testing.T) {
 	r, mockService := setupTest()
 
 	t.Run("success", func(t *testing.T) {
-		mockService.On("Delete", mock.Anything, 1).Return(nil).Once()
+		mockService.On("Delete", mock.Anything, 1, 0).Return(nil).Once()
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodDelete, "/todos/1", nil)
@@ -201,4 +495,15 @@ func TestDeleteTodo(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockService.On("Delete", mock.Anything, 999, 0).Return(apperrors.NotFound("todo 999 not found")).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, "/todos/999", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, apperrors.CodeNotFound, decodeProblem(t, w).Code)
+	})
 }
@@ -1,42 +1,163 @@
 package core
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
 	"tuhuynh.com/go-ioc-gin-example/logger"
 
 	"github.com/gin-gonic/gin"
 	"tuhuynh.com/go-ioc-gin-example/config"
 	"tuhuynh.com/go-ioc-gin-example/controllers"
+	"tuhuynh.com/go-ioc-gin-example/database"
+	"tuhuynh.com/go-ioc-gin-example/middleware"
 	"tuhuynh.com/go-ioc-gin-example/migrations"
+	"tuhuynh.com/go-ioc-gin-example/security"
 )
 
 type Application struct {
-	Component       struct{}
-	Config          *config.Config              `autowired:"true"`
-	Log             logger.Logger               `autowired:"true"`
-	HealthCheck     *HealthCheck                `autowired:"true"`
-	TodoController  *controllers.TodoController `autowired:"true"`
-	MigrationRunner *migrations.Runner          `autowired:"true"`
+	Component        struct{}
+	Config           *config.Config                `autowired:"true"`
+	Log              logger.Logger                 `autowired:"true"`
+	HealthCheck      *HealthCheck                  `autowired:"true"`
+	RequestLogger    *middleware.RequestLogger     `autowired:"true"`
+	Recovery         *middleware.Recovery          `autowired:"true"`
+	ErrorHandler     *middleware.ErrorHandler      `autowired:"true"`
+	RateLimiter      security.RateLimiter          `autowired:"true" qualifier:"redis"`
+	Authenticator    *security.Authenticator       `autowired:"true"`
+	TodoController   *controllers.TodoController   `autowired:"true"`
+	APIKeyController *controllers.APIKeyController `autowired:"true"`
+	MigrationRunner  *migrations.Runner            `autowired:"true"`
+	Resolver         *database.Resolver            `autowired:"true"`
+
+	mu       sync.Mutex
+	server   *http.Server
+	shutdown bool
+}
+
+// Router builds the gin engine with every route registered. Access logging
+// and panic recovery go through our own middleware instead of gin's defaults.
+func (a *Application) Router() *gin.Engine {
+	router := gin.New()
+	router.Use(a.RequestLogger.Handle, a.Recovery.Handle, a.ErrorHandler.Handle, a.Resolver.Handle)
+	router.NoRoute(a.ErrorHandler.NoRoute)
+
+	// Health check endpoints
+	router.GET("/health", a.HealthCheck.Ready)
+	router.GET("/health/live", a.HealthCheck.Live)
+	router.GET("/health/ready", a.HealthCheck.Ready)
+
+	// Every request is limited by IP first, so failed authentication
+	// attempts are too. Callers are then authenticated and authorized before
+	// the route's own limits so those can be keyed by user.
+	auth := security.Middleware(a.RateLimiter, security.NewPolicy(a.Config, "auth"))
+	for _, r := range a.routes() {
+		handlers := append([]gin.HandlerFunc{auth, a.Authenticator.Handle, security.RequirePermission(r.permission)}, r.handlers...)
+		router.Handle(r.method, r.path, handlers...)
+	}
+
+	return router
+}
+
+// route is an authenticated endpoint and the permission callers need for it
+type route struct {
+	method     string
+	path       string
+	permission security.Permission
+	handlers   []gin.HandlerFunc
+}
+
+// routes declares every authenticated endpoint with its required permission
+func (a *Application) routes() []route {
+	// Reads and writes are limited separately so a burst of writes can't starve reads
+	read := security.Middleware(a.RateLimiter, security.NewPolicy(a.Config, "read"))
+	write := security.Middleware(a.RateLimiter, security.NewPolicy(a.Config, "write"))
+
+	return []route{
+		{http.MethodGet, "/todos", security.PermissionTodosRead, []gin.HandlerFunc{read, a.TodoController.ListTodos}},
+		{http.MethodPost, "/todos", security.PermissionTodosWrite, []gin.HandlerFunc{write, a.TodoController.CreateTodo}},
+		{http.MethodGet, "/todos/:id", security.PermissionTodosRead, []gin.HandlerFunc{read, a.TodoController.GetTodo}},
+		{http.MethodPut, "/todos/:id", security.PermissionTodosWrite, []gin.HandlerFunc{write, a.TodoController.UpdateTodo}},
+		{http.MethodPatch, "/todos/:id", security.PermissionTodosWrite, []gin.HandlerFunc{write, a.TodoController.PatchTodo}},
+		{http.MethodDelete, "/todos/:id", security.PermissionTodosWrite, []gin.HandlerFunc{write, a.TodoController.DeleteTodo}},
+
+		{http.MethodGet, "/admin/api-keys", security.PermissionAdmin, []gin.HandlerFunc{a.APIKeyController.ListAPIKeys}},
+		{http.MethodPost, "/admin/api-keys", security.PermissionAdmin, []gin.HandlerFunc{a.APIKeyController.CreateAPIKey}},
+		{http.MethodPost, "/admin/api-keys/:id/rotate", security.PermissionAdmin, []gin.HandlerFunc{a.APIKeyController.RotateAPIKey}},
+		{http.MethodDelete, "/admin/api-keys/:id", security.PermissionAdmin, []gin.HandlerFunc{a.APIKeyController.RevokeAPIKey}},
+
+		// Rate limiter metrics
+		{http.MethodGet, "/debug/vars", security.PermissionAdmin, []gin.HandlerFunc{security.StatsHandler}},
+	}
 }
 
-func (a *Application) Run() {
-	// Run database migrations
-	if err := a.MigrationRunner.Run(); err != nil {
-		a.Log.Fatal("Failed to run migrations: %v", err)
+// Start serves HTTP until Shutdown is called, applying migrations alongside
+// so slow ones don't hold up the liveness probe. It fails right away when the
+// schema is behind this build and migrations on boot are verify-only, and
+// returns nil once the server has been shut down gracefully.
+func (a *Application) Start(ctx context.Context) error {
+	if err := a.MigrationRunner.Boot(ctx); err != nil {
+		return err
 	}
 
-	router := gin.Default()
+	a.mu.Lock()
+	if a.shutdown {
+		a.mu.Unlock()
+		return nil
+	}
+	a.server = &http.Server{
+		Addr:    a.Config.Addr(),
+		Handler: a.Router(),
+	}
+	server := a.server
+	a.mu.Unlock()
 
-	// Health check endpoint
-	router.GET("/health", a.HealthCheck.Check)
+	a.Log.Infow("Listening", "addr", a.Config.Addr())
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
 
-	router.GET("/todos", a.TodoController.ListTodos)
-	router.POST("/todos", a.TodoController.CreateTodo)
-	router.GET("/todos/:id", a.TodoController.GetTodo)
-	router.PUT("/todos/:id", a.TodoController.UpdateTodo)
-	router.DELETE("/todos/:id", a.TodoController.DeleteTodo)
+// Shutdown stops the server gracefully. Readiness is flipped to failing first
+// so load balancers stop routing new traffic, and after Config.ShutdownDelay
+// in-flight requests are drained for up to Config.ShutdownTimeout before
+// connections are closed. Components must only be destroyed after Shutdown
+// returns.
+func (a *Application) Shutdown(ctx context.Context) error {
+	a.mu.Lock()
+	a.shutdown = true
+	server := a.server
+	a.mu.Unlock()
 
-	err := router.Run(a.Config.Port)
-	if err != nil {
-		a.Log.Fatal("Failed to start server: %v", err)
+	a.HealthCheck.SetDraining()
+	if server == nil {
+		return nil
 	}
+
+	// Give load balancers time to observe the failing readiness probe. Requests
+	// are still served meanwhile, so the drain timeout only starts afterwards.
+	if delay := a.Config.ShutdownDelay; delay > 0 {
+		a.Log.Infow("Readiness failing, waiting before draining connections", "delay", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.Config.ShutdownTimeout)
+	defer cancel()
+
+	a.Log.Info("Draining in-flight requests")
+	if err := server.Shutdown(ctx); err != nil {
+		a.Log.Errorw("Graceful shutdown timed out, closing remaining connections", "error", err)
+		server.Close()
+		return err
+	}
+
+	a.Log.Info("Server stopped")
+	return nil
 }
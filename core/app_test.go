@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/controllers"
+	"tuhuynh.com/go-ioc-gin-example/database"
+	"tuhuynh.com/go-ioc-gin-example/health"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+	"tuhuynh.com/go-ioc-gin-example/middleware"
+	"tuhuynh.com/go-ioc-gin-example/migrations"
+	"tuhuynh.com/go-ioc-gin-example/security"
+)
+
+// newTestApp returns an application listening on a free port, without a
+// database and with migrations on boot turned off
+func newTestApp(t *testing.T, cfg *config.Config) *Application {
+	gin.SetMode(gin.TestMode)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	cfg.Port = listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	cfg.MigrateOnBoot = config.MigrateOnBootOff
+
+	log := logger.NewTestLogger()
+	limiter := &security.InMemoryRateLimiter{Config: cfg}
+	limiter.PostConstruct()
+	t.Cleanup(limiter.PreDestroy)
+
+	return &Application{
+		Config:           cfg,
+		Log:              log,
+		HealthCheck:      &HealthCheck{Config: cfg},
+		RequestLogger:    &middleware.RequestLogger{Log: log},
+		Recovery:         &middleware.Recovery{Log: log},
+		ErrorHandler:     &middleware.ErrorHandler{Log: log},
+		RateLimiter:      limiter,
+		Authenticator:    &security.Authenticator{Log: log},
+		TodoController:   &controllers.TodoController{},
+		APIKeyController: &controllers.APIKeyController{},
+		MigrationRunner:  &migrations.Runner{Config: cfg, Log: log},
+		Resolver:         &database.Resolver{Config: cfg, Log: log},
+	}
+}
+
+// client opens a connection per request, as the server waits on connections
+// kept open without a request when shutting down
+var client = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+// get requests path from the application and returns the status, or 0 when
+// the request fails
+func get(app *Application, path string) int {
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d%s", app.Config.Port, path))
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestApplicationShutdownBeforeStart(t *testing.T) {
+	app := newTestApp(t, &config.Config{ShutdownTimeout: time.Second})
+
+	require.NoError(t, app.Shutdown(context.Background()))
+	// Start returns without listening, as the process is already stopping
+	assert.NoError(t, app.Start(context.Background()))
+}
+
+func TestApplicationShutdown(t *testing.T) {
+	// The delay outlasts the drain timeout, which must not cut short requests
+	// that finish soon after draining starts
+	app := newTestApp(t, &config.Config{ShutdownDelay: 500 * time.Millisecond, ShutdownTimeout: 400 * time.Millisecond})
+
+	started := make(chan error, 1)
+	go func() { started <- app.Start(context.Background()) }()
+	require.Eventually(t, func() bool {
+		return get(app, "/health/live") == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+
+	// A readiness probe stays in flight until released
+	probing := make(chan struct{})
+	release := make(chan struct{})
+	app.HealthCheck.Register(health.Check{Name: "slow", Timeout: 5 * time.Second, Probe: func(ctx context.Context) error {
+		close(probing)
+		<-release
+		return nil
+	}})
+	inFlight := make(chan int, 1)
+	go func() { inFlight <- get(app, "/health/ready") }()
+	<-probing
+
+	stopping := time.Now()
+	stopped := make(chan error, 1)
+	go func() { stopped <- app.Shutdown(context.Background()) }()
+
+	// Readiness fails at once, while requests are still served during the delay
+	assert.Eventually(t, func() bool {
+		return get(app, "/health/ready") == http.StatusServiceUnavailable
+	}, 100*time.Millisecond, 10*time.Millisecond)
+	assert.Equal(t, http.StatusOK, get(app, "/health/live"))
+
+	// Draining starts after the delay, and the in-flight request finishing
+	// then is waited for
+	log := app.Log.(*logger.TestLogger)
+	require.Eventually(t, func() bool {
+		for _, entry := range log.Entries() {
+			if entry.Message == "Draining in-flight requests" {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, time.Since(stopping), 500*time.Millisecond)
+	close(release)
+	assert.Equal(t, http.StatusOK, <-inFlight)
+	assert.NoError(t, <-stopped)
+	assert.NoError(t, <-started)
+}
@@ -2,16 +2,74 @@ package core
 
 import (
 	"net/http"
+	"sync"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/database"
+	"tuhuynh.com/go-ioc-gin-example/health"
+	"tuhuynh.com/go-ioc-gin-example/migrations"
 )
 
+// HealthCheck serves the liveness and readiness probes. Components contribute
+// dependency checks to its registry, and readiness fails when a critical one does.
 type HealthCheck struct {
-	Component struct{} `implements:"HealthCheck"`
+	Component       struct{}           `implements:"HealthCheck"`
+	Config          *config.Config     `autowired:"true"`
+	MigrationRunner *migrations.Runner `autowired:"true"`
+	Resolver        *database.Resolver `autowired:"true"`
+
+	draining atomic.Bool
+	mu       sync.RWMutex
+	checks   []health.Check
+}
+
+// PostConstruct registers the checks of the components owning dependencies
+func (h *HealthCheck) PostConstruct() {
+	for _, contributor := range []health.Contributor{h.Config, h.MigrationRunner, h.Resolver} {
+		h.Register(contributor.HealthChecks()...)
+	}
+}
+
+// Register adds checks to the readiness probe
+func (h *HealthCheck) Register(checks ...health.Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks = append(h.checks, checks...)
 }
 
-func (h *HealthCheck) Check(ctx *gin.Context) {
+// SetDraining makes readiness fail so no new traffic is routed here during shutdown
+func (h *HealthCheck) SetDraining() {
+	h.draining.Store(true)
+}
+
+// Live reports whether the process is up. It never checks dependencies, so an
+// outage of MySQL or Redis doesn't get the process restarted.
+func (h *HealthCheck) Live(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{
-		"status": "healthy",
+		"status": health.StatusUp,
 	})
 }
+
+// Ready runs every registered check and answers 503 when a critical one fails
+func (h *HealthCheck) Ready(ctx *gin.Context) {
+	if h.draining.Load() {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "draining",
+		})
+		return
+	}
+
+	h.mu.RLock()
+	checks := append([]health.Check(nil), h.checks...)
+	h.mu.RUnlock()
+
+	report := health.Run(ctx.Request.Context(), checks)
+	status := http.StatusOK
+	if report.Status == health.StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, report)
+}
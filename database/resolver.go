@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/health"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+// Resolver picks the connection for each query: writes go to the primary,
+// reads are spread round-robin over the healthy read replicas. Replicas are
+// pinged every Config.DBReplicaCheckInterval and ejected while they fail.
+// Within TxManager.WithinTx both reads and writes use its transaction.
+//
+// Cached reads are filled from the primary with ReadFromPrimary, as a lagging
+// replica would keep stale data in the cache until it expires. The replicas
+// therefore only serve the reads that aren't cached, which is all of them
+// while Redis is down.
+type Resolver struct {
+	Component struct{}
+	Config    *config.Config `autowired:"true"`
+	Log       logger.Logger  `autowired:"true"`
+
+	replicas []*replica
+	next     atomic.Uint64
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+type replica struct {
+	name    string
+	db      *gorm.DB
+	healthy atomic.Bool
+}
+
+func (r *Resolver) PostConstruct() {
+	for i, db := range r.Config.Replicas {
+		r.replicas = append(r.replicas, &replica{name: fmt.Sprintf("replica-%d", i+1), db: db})
+	}
+	if len(r.replicas) == 0 {
+		return
+	}
+
+	// Replicas only take reads once they answered
+	r.check(context.Background())
+
+	r.stop = make(chan struct{})
+	r.wg.Add(1)
+	go r.watch()
+}
+
+func (r *Resolver) PreDestroy() {
+	if r.stop != nil {
+		close(r.stop)
+		r.wg.Wait()
+	}
+}
+
+// Writer returns the primary for ctx, and sends the following reads of the
+// request to the primary too for Config.DBReadYourWritesWindow
+func (r *Resolver) Writer(ctx context.Context) *gorm.DB {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.lastWrite.Store(time.Now().UnixNano())
+	}
+	if uow, ok := unitOfWorkFrom(ctx); ok && uow.tx != nil {
+		return uow.tx.WithContext(ctx)
+	}
+	return r.Config.DB.WithContext(ctx)
+}
+
+// Reader returns a healthy replica for ctx, or the primary when there is
+// none, the request wrote recently or ctx is from ReadFromPrimary
+func (r *Resolver) Reader(ctx context.Context) *gorm.DB {
+	if ctx.Value(primaryKey{}) != nil {
+		return r.Config.DB.WithContext(ctx)
+	}
+	if uow, ok := unitOfWorkFrom(ctx); ok && uow.tx != nil {
+		return uow.tx.WithContext(ctx)
+	}
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok && s.wroteWithin(r.Config.DBReadYourWritesWindow) {
+		return r.Config.DB.WithContext(ctx)
+	}
+
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if replica := r.replicas[(start+i)%n]; replica.healthy.Load() {
+			return replica.db.WithContext(ctx)
+		}
+	}
+	return r.Config.DB.WithContext(ctx)
+}
+
+// watch pings the replicas until PreDestroy
+func (r *Resolver) watch() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.Config.DBReplicaCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.check(context.Background())
+		}
+	}
+}
+
+// check pings every replica, ejecting those that fail and restoring those
+// that answer again
+func (r *Resolver) check(ctx context.Context) {
+	for _, replica := range r.replicas {
+		err := r.ping(ctx, replica)
+		healthy := err == nil
+		if replica.healthy.Swap(healthy) == healthy {
+			continue
+		}
+		if healthy {
+			r.Log.Infow("Read replica is back, routing reads to it", "replica", replica.name)
+		} else {
+			r.Log.Warnw("Read replica failed, ejecting it", "replica", replica.name, "error", err)
+		}
+	}
+}
+
+func (r *Resolver) ping(ctx context.Context, replica *replica) error {
+	ctx, cancel := context.WithTimeout(ctx, r.Config.HealthCheckTimeout)
+	defer cancel()
+
+	sqlDB, err := replica.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// HealthChecks reports when every replica is ejected. Reads then go to the
+// primary, so it isn't critical.
+func (r *Resolver) HealthChecks() []health.Check {
+	if len(r.replicas) == 0 {
+		return nil
+	}
+	return []health.Check{
+		{
+			Name:     "replicas",
+			Critical: false,
+			Probe: func(ctx context.Context) error {
+				for _, replica := range r.replicas {
+					if replica.healthy.Load() {
+						return nil
+					}
+				}
+				return errors.New("every read replica is ejected, reading from the primary")
+			},
+		},
+	}
+}
+
+// Handle starts a session for the request, which keeps its reads on the
+// primary after it wrote
+func (r *Resolver) Handle(ctx *gin.Context) {
+	ctx.Request = ctx.Request.WithContext(WithSession(ctx.Request.Context()))
+	ctx.Next()
+}
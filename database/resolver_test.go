@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+// openNamed opens an in-memory database that answers queries for its name
+func openNamed(t *testing.T, name string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// Every connection to :memory: is a new database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	require.NoError(t, db.Exec("CREATE TABLE names (name TEXT)").Error)
+	require.NoError(t, db.Exec("INSERT INTO names (name) VALUES (?)", name).Error)
+	return db
+}
+
+// name returns the name of the database db reads from
+func name(t *testing.T, db *gorm.DB) string {
+	var name string
+	require.NoError(t, db.Raw("SELECT name FROM names").Scan(&name).Error)
+	return name
+}
+
+func newTestResolver(t *testing.T, replicas ...string) *Resolver {
+	cfg := &config.Config{
+		DB:                     openNamed(t, "primary"),
+		DBReplicaCheckInterval: time.Hour,
+		DBReadYourWritesWindow: 50 * time.Millisecond,
+		HealthCheckTimeout:     time.Second,
+	}
+	for _, replica := range replicas {
+		cfg.Replicas = append(cfg.Replicas, openNamed(t, replica))
+	}
+
+	r := &Resolver{Config: cfg, Log: logger.NewTestLogger()}
+	r.PostConstruct()
+	t.Cleanup(r.PreDestroy)
+	return r
+}
+
+func TestResolverRoundRobin(t *testing.T) {
+	ctx := context.Background()
+	r := newTestResolver(t, "replica-1", "replica-2")
+
+	reads := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		reads[name(t, r.Reader(ctx))]++
+	}
+	assert.Equal(t, map[string]int{"replica-1": 2, "replica-2": 2}, reads)
+	assert.Equal(t, "primary", name(t, r.Writer(ctx)))
+
+	// Without replicas everything goes to the primary
+	r = newTestResolver(t)
+	assert.Equal(t, "primary", name(t, r.Reader(ctx)))
+	assert.Empty(t, r.HealthChecks())
+}
+
+func TestResolverReadYourWrites(t *testing.T) {
+	r := newTestResolver(t, "replica")
+	ctx := WithSession(context.Background())
+
+	assert.Equal(t, "replica", name(t, r.Reader(ctx)))
+	r.Writer(ctx)
+	assert.Equal(t, "primary", name(t, r.Reader(ctx)))
+
+	// Other requests aren't affected
+	assert.Equal(t, "replica", name(t, r.Reader(WithSession(context.Background()))))
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, "replica", name(t, r.Reader(ctx)))
+}
+
+func TestResolverReadFromPrimary(t *testing.T) {
+	r := newTestResolver(t, "replica")
+	ctx := ReadFromPrimary(context.Background())
+
+	assert.Equal(t, "primary", name(t, r.Reader(ctx)))
+	assert.Equal(t, "replica", name(t, r.Reader(context.Background())))
+}
+
+func TestResolverEjection(t *testing.T) {
+	ctx := context.Background()
+	r := newTestResolver(t, "replica-1", "replica-2")
+	probe := r.HealthChecks()[0].Probe
+	assert.NoError(t, probe(ctx))
+
+	sqlDB, err := r.Config.Replicas[0].DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+	r.check(ctx)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "replica-2", name(t, r.Reader(ctx)))
+	}
+
+	// A replica answering again is restored
+	r.replicas[1].healthy.Store(false)
+	assert.Equal(t, "primary", name(t, r.Reader(ctx)))
+	assert.ErrorContains(t, probe(ctx), "every read replica is ejected")
+
+	r.check(ctx)
+	assert.Equal(t, "replica-2", name(t, r.Reader(ctx)))
+	assert.NoError(t, probe(ctx))
+}
+
+func TestResolverHandle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := newTestResolver(t, "replica")
+
+	router := gin.New()
+	router.Use(r.Handle)
+	router.POST("/", func(ctx *gin.Context) {
+		reqCtx := ctx.Request.Context()
+		r.Writer(reqCtx)
+		ctx.String(http.StatusOK, name(t, r.Reader(reqCtx)))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, "primary", w.Body.String())
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+type sessionKey struct{}
+
+// session remembers when a request last wrote to the primary
+type session struct {
+	lastWrite atomic.Int64
+}
+
+// WithSession returns a context whose reads are sent to the primary for a
+// while after a write through it, as a replica may not have that write yet
+func WithSession(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(sessionKey{}).(*session); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+type primaryKey struct{}
+
+// ReadFromPrimary returns a context whose reads go to the primary, outside
+// of any transaction of ctx. It suits reads that outlive the request, such as
+// cache fills, which must not keep a lagging replica's or an uncommitted
+// transaction's view of the data.
+func ReadFromPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// wroteWithin reports whether the session wrote in the last window
+func (s *session) wroteWithin(window time.Duration) bool {
+	last := s.lastWrite.Load()
+	return last != 0 && time.Since(time.Unix(0, last)) < window
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// TxManager runs units of work atomically. Implementations are selected by
+// qualifier: "sql" runs them in a database transaction, "mock" runs them
+// against the in-memory repositories.
+type TxManager interface {
+	// WithinTx runs fn in a transaction carried by the context it is given,
+	// committing when fn returns nil and rolling back when it fails or
+	// panics. Called within a transaction, fn joins it.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type unitOfWorkKey struct{}
+
+// unitOfWork is the transaction of a context and what to do once it commits
+type unitOfWork struct {
+	tx *gorm.DB
+
+	mu          sync.Mutex
+	afterCommit []func()
+	// batches holds the items of AfterCommitBatched by key
+	batches map[string]*[]string
+	// onRollback undoes the writes of in-memory repositories
+	onRollback []func()
+}
+
+func withUnitOfWork(ctx context.Context, uow *unitOfWork) context.Context {
+	return context.WithValue(ctx, unitOfWorkKey{}, uow)
+}
+
+func unitOfWorkFrom(ctx context.Context) (*unitOfWork, bool) {
+	uow, ok := ctx.Value(unitOfWorkKey{}).(*unitOfWork)
+	return uow, ok
+}
+
+// InTx reports whether ctx carries a transaction. Reads within one must go
+// through it to see its writes, and what they return must not be shared, as
+// the transaction may still roll back.
+func InTx(ctx context.Context) bool {
+	_, ok := unitOfWorkFrom(ctx)
+	return ok
+}
+
+// AfterCommit runs fn once the transaction of ctx commits, or right away
+// outside of one. It suits side effects such as cache invalidation, which
+// must neither run for a rolled back change nor before others can see it.
+func AfterCommit(ctx context.Context, fn func()) {
+	uow, ok := unitOfWorkFrom(ctx)
+	if !ok {
+		fn()
+		return
+	}
+
+	uow.mu.Lock()
+	defer uow.mu.Unlock()
+	uow.afterCommit = append(uow.afterCommit, fn)
+}
+
+// AfterCommitBatched is AfterCommit for callbacks handling many items, such
+// as cache keys to drop. Within a transaction the items given under the same
+// key are collected and fn, the first one given for key, runs once with all
+// of them.
+func AfterCommitBatched(ctx context.Context, key string, items []string, fn func(items []string)) {
+	uow, ok := unitOfWorkFrom(ctx)
+	if !ok {
+		fn(items)
+		return
+	}
+
+	uow.mu.Lock()
+	defer uow.mu.Unlock()
+	if batch, ok := uow.batches[key]; ok {
+		*batch = append(*batch, items...)
+		return
+	}
+	if uow.batches == nil {
+		uow.batches = make(map[string]*[]string)
+	}
+	batch := append([]string(nil), items...)
+	uow.batches[key] = &batch
+	uow.afterCommit = append(uow.afterCommit, func() { fn(batch) })
+}
+
+// OnRollback runs fn if the transaction of ctx rolls back, so in-memory
+// repositories can undo a write as a database would. Outside of a
+// transaction it does nothing.
+func OnRollback(ctx context.Context, fn func()) {
+	uow, ok := unitOfWorkFrom(ctx)
+	if !ok {
+		return
+	}
+
+	uow.mu.Lock()
+	defer uow.mu.Unlock()
+	uow.onRollback = append(uow.onRollback, fn)
+}
+
+// committed runs the callbacks registered with AfterCommit
+func (uow *unitOfWork) committed() {
+	uow.mu.Lock()
+	callbacks := uow.afterCommit
+	uow.afterCommit = nil
+	uow.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn()
+	}
+}
+
+// rolledBack runs the callbacks registered with OnRollback, latest first
+func (uow *unitOfWork) rolledBack() {
+	uow.mu.Lock()
+	callbacks := uow.onRollback
+	uow.onRollback = nil
+	uow.mu.Unlock()
+
+	for i := len(callbacks) - 1; i >= 0; i-- {
+		callbacks[i]()
+	}
+}
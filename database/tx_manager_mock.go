@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"sync"
+)
+
+// TxManagerMock runs units of work against the in-memory repositories, which
+// undo their writes when one rolls back. Units of work aren't isolated from
+// each other. Commits and Rollbacks count the outcomes for tests.
+type TxManagerMock struct {
+	Component struct{} `implements:"TxManager"`
+	Qualifier struct{} `value:"mock"`
+
+	mu        sync.Mutex
+	Commits   int
+	Rollbacks int
+}
+
+func (m *TxManagerMock) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := unitOfWorkFrom(ctx); ok {
+		return fn(ctx)
+	}
+
+	uow := &unitOfWork{}
+	defer func() {
+		if p := recover(); p != nil {
+			m.rollback(uow)
+			panic(p)
+		}
+		if err != nil {
+			m.rollback(uow)
+			return
+		}
+		m.mu.Lock()
+		m.Commits++
+		m.mu.Unlock()
+		uow.committed()
+	}()
+
+	return fn(withUnitOfWork(ctx, uow))
+}
+
+func (m *TxManagerMock) rollback(uow *unitOfWork) {
+	m.mu.Lock()
+	m.Rollbacks++
+	m.mu.Unlock()
+	uow.rolledBack()
+}
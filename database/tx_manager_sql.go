@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// TxManagerSql runs units of work in a transaction on the primary, which the
+// Resolver hands to every query made with the context of fn
+type TxManagerSql struct {
+	Component struct{}  `implements:"TxManager"`
+	Qualifier struct{}  `value:"sql"`
+	Resolver  *Resolver `autowired:"true"`
+}
+
+func (m *TxManagerSql) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := unitOfWorkFrom(ctx); ok {
+		return fn(ctx)
+	}
+
+	// Transaction rolls back when fn returns an error or panics
+	uow := &unitOfWork{}
+	defer func() {
+		if p := recover(); p != nil {
+			uow.rolledBack()
+			panic(p)
+		}
+	}()
+	err := m.Resolver.Writer(ctx).Transaction(func(tx *gorm.DB) error {
+		uow.tx = tx
+		return fn(withUnitOfWork(ctx, uow))
+	})
+	if err != nil {
+		uow.rolledBack()
+		return err
+	}
+
+	uow.committed()
+	return nil
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func count(t *testing.T, r *Resolver) int64 {
+	var n int64
+	require.NoError(t, r.Config.DB.Table("names").Count(&n).Error)
+	return n
+}
+
+func TestTxManagerSql(t *testing.T) {
+	ctx := context.Background()
+	r := newTestResolver(t, "replica")
+	m := &TxManagerSql{Resolver: r}
+	insert := func(ctx context.Context) error {
+		return r.Writer(ctx).Exec("INSERT INTO names (name) VALUES ('added')").Error
+	}
+
+	var committed bool
+	err := m.WithinTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, insert(ctx))
+		// Reads within the transaction see its writes
+		var n int64
+		require.NoError(t, r.Reader(ctx).Table("names").Count(&n).Error)
+		assert.Equal(t, int64(2), n)
+
+		AfterCommit(ctx, func() { committed = true })
+		assert.False(t, committed)
+
+		// Nested units of work join the transaction
+		return m.WithinTx(ctx, insert)
+	})
+	require.NoError(t, err)
+	assert.True(t, committed)
+	assert.Equal(t, int64(3), count(t, r))
+
+	// Errors roll back and skip the callbacks
+	failure := errors.New("failed")
+	committed = false
+	err = m.WithinTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, insert(ctx))
+		AfterCommit(ctx, func() { committed = true })
+		return m.WithinTx(ctx, func(ctx context.Context) error { return failure })
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.False(t, committed)
+	assert.Equal(t, int64(3), count(t, r))
+
+	// So do panics, which are passed on
+	assert.PanicsWithValue(t, "boom", func() {
+		m.WithinTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, insert(ctx))
+			panic("boom")
+		})
+	})
+	assert.Equal(t, int64(3), count(t, r))
+
+	// Outside a transaction callbacks run right away
+	committed = false
+	AfterCommit(ctx, func() { committed = true })
+	assert.True(t, committed)
+}
+
+func TestTxManagerMock(t *testing.T) {
+	ctx := context.Background()
+	m := &TxManagerMock{}
+
+	var committed int
+	require.NoError(t, m.WithinTx(ctx, func(ctx context.Context) error {
+		AfterCommit(ctx, func() { committed++ })
+		return m.WithinTx(ctx, func(ctx context.Context) error { return nil })
+	}))
+	assert.Equal(t, 1, committed)
+
+	assert.Error(t, m.WithinTx(ctx, func(ctx context.Context) error {
+		AfterCommit(ctx, func() { committed++ })
+		return errors.New("failed")
+	}))
+	assert.Panics(t, func() {
+		m.WithinTx(ctx, func(ctx context.Context) error { panic("boom") })
+	})
+	assert.Equal(t, 1, committed)
+	assert.Equal(t, 1, m.Commits)
+	assert.Equal(t, 2, m.Rollbacks)
+
+	// Writes are undone latest first
+	var undone []int
+	assert.Error(t, m.WithinTx(ctx, func(ctx context.Context) error {
+		OnRollback(ctx, func() { undone = append(undone, 1) })
+		OnRollback(ctx, func() { undone = append(undone, 2) })
+		return errors.New("failed")
+	}))
+	assert.Equal(t, []int{2, 1}, undone)
+
+	// Units of work started from another goroutine don't wait for this one
+	require.NoError(t, m.WithinTx(ctx, func(ctx context.Context) error {
+		done := make(chan error)
+		go func() {
+			done <- m.WithinTx(context.Background(), func(ctx context.Context) error { return nil })
+		}()
+		return <-done
+	}))
+	assert.Equal(t, 3, m.Commits)
+}
+
+func TestAfterCommitBatched(t *testing.T) {
+	ctx := context.Background()
+	m := &TxManagerMock{}
+
+	var batches [][]string
+	collect := func(items []string) { batches = append(batches, items) }
+	require.NoError(t, m.WithinTx(ctx, func(ctx context.Context) error {
+		AfterCommitBatched(ctx, "a", []string{"1"}, collect)
+		AfterCommitBatched(ctx, "b", []string{"x"}, collect)
+		AfterCommitBatched(ctx, "a", []string{"2", "3"}, collect)
+		assert.Empty(t, batches)
+		return nil
+	}))
+	assert.Equal(t, [][]string{{"1", "2", "3"}, {"x"}}, batches)
+
+	// Outside a transaction every call runs right away
+	batches = nil
+	AfterCommitBatched(ctx, "a", []string{"1"}, collect)
+	AfterCommitBatched(ctx, "a", []string{"2"}, collect)
+	assert.Equal(t, [][]string{{"1"}, {"2"}}, batches)
+}
@@ -0,0 +1,61 @@
+package dto
+
+import (
+	"time"
+
+	"tuhuynh.com/go-ioc-gin-example/entities"
+)
+
+// CreateAPIKeyRequest is the body accepted by POST /admin/api-keys
+type CreateAPIKeyRequest struct {
+	Name    string   `json:"name" binding:"required,notblank,max=100"`
+	Subject string   `json:"subject" binding:"max=191"`
+	Scopes  []string `json:"scopes" binding:"required,min=1,dive,oneof=todos:read todos:write admin"`
+}
+
+// APIKeyResponse describes an API key without its secret
+type APIKeyResponse struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Subject    string     `json:"subject"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// MintedAPIKeyResponse is returned when a key is minted or rotated and is the
+// only response that carries the secret
+type MintedAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// NewAPIKeyResponse maps an API key entity onto its response representation
+func NewAPIKeyResponse(key entities.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Subject:    key.Subject,
+		Scopes:     key.ScopeList(),
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+// NewMintedAPIKeyResponse adds the secret to the response of a minted or rotated key
+func NewMintedAPIKeyResponse(key entities.APIKey, secret string) MintedAPIKeyResponse {
+	return MintedAPIKeyResponse{APIKeyResponse: NewAPIKeyResponse(key), Key: secret}
+}
+
+// NewAPIKeyListResponse maps every API key onto its response representation
+func NewAPIKeyListResponse(keys []entities.APIKey) []APIKeyResponse {
+	responses := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		responses = append(responses, NewAPIKeyResponse(key))
+	}
+	return responses
+}
@@ -0,0 +1,144 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"tuhuynh.com/go-ioc-gin-example/entities"
+	"tuhuynh.com/go-ioc-gin-example/repositories"
+)
+
+// CreateTodoRequest is the body accepted by POST /todos
+type CreateTodoRequest struct {
+	Title       string `json:"title" binding:"required,notblank,max=200"`
+	Description string `json:"description" binding:"max=2000"`
+	Completed   bool   `json:"completed"`
+}
+
+// UpdateTodoRequest is the body accepted by PUT /todos/:id and replaces every editable field
+type UpdateTodoRequest struct {
+	Title       string `json:"title" binding:"required,notblank,max=200"`
+	Description string `json:"description" binding:"max=2000"`
+	Completed   bool   `json:"completed"`
+}
+
+// PatchTodoRequest is a JSON Merge Patch (RFC 7396) document for PATCH /todos/:id.
+// Absent members are left untouched and an explicit null resets a member to its
+// zero value, which for the title then fails validation.
+type PatchTodoRequest struct {
+	Title       *string `json:"title" binding:"omitempty,notblank,max=200"`
+	Description *string `json:"description" binding:"omitempty,max=2000"`
+	Completed   *bool   `json:"completed"`
+}
+
+// UnmarshalJSON decodes the merge patch, telling explicit nulls apart from absent members
+func (r *PatchTodoRequest) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	if members == nil {
+		return errors.New("merge patch must be a JSON object")
+	}
+
+	*r = PatchTodoRequest{}
+	if err := decodeMember(members, "title", &r.Title); err != nil {
+		return err
+	}
+	if err := decodeMember(members, "description", &r.Description); err != nil {
+		return err
+	}
+	return decodeMember(members, "completed", &r.Completed)
+}
+
+// decodeMember sets *dst when the member is present, using the zero value for null
+func decodeMember[T any](members map[string]json.RawMessage, name string, dst **T) error {
+	raw, ok := members[name]
+	if !ok {
+		return nil
+	}
+
+	value := new(T)
+	if string(raw) != "null" {
+		if err := json.Unmarshal(raw, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	*dst = value
+	return nil
+}
+
+// TodoResponse is the representation of a todo returned to clients
+type TodoResponse struct {
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Completed   bool      `json:"completed"`
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TodoListResponse is the paginated envelope returned by GET /todos
+type TodoListResponse struct {
+	Data       []TodoResponse `json:"data"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+// ToEntity maps the request onto a new todo
+func (r CreateTodoRequest) ToEntity() entities.Todo {
+	return entities.Todo{
+		Title:       strings.TrimSpace(r.Title),
+		Description: strings.TrimSpace(r.Description),
+		Completed:   r.Completed,
+	}
+}
+
+// ToEntity maps the request onto the todo identified by id
+func (r UpdateTodoRequest) ToEntity(id int) entities.Todo {
+	return entities.Todo{
+		ID:          id,
+		Title:       strings.TrimSpace(r.Title),
+		Description: strings.TrimSpace(r.Description),
+		Completed:   r.Completed,
+	}
+}
+
+// ToPatch maps the merge patch onto a repository patch
+func (r PatchTodoRequest) ToPatch() repositories.TodoPatch {
+	patch := repositories.TodoPatch{Completed: r.Completed}
+	if r.Title != nil {
+		title := strings.TrimSpace(*r.Title)
+		patch.Title = &title
+	}
+	if r.Description != nil {
+		description := strings.TrimSpace(*r.Description)
+		patch.Description = &description
+	}
+	return patch
+}
+
+// NewTodoResponse maps a todo entity onto its response representation
+func NewTodoResponse(todo entities.Todo) TodoResponse {
+	return TodoResponse{
+		ID:          todo.ID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Completed:   todo.Completed,
+		Version:     todo.Version,
+		CreatedAt:   todo.CreatedAt,
+		UpdatedAt:   todo.UpdatedAt,
+	}
+}
+
+// NewTodoListResponse maps a page of todos onto the list envelope
+func NewTodoListResponse(page repositories.TodoPage) TodoListResponse {
+	data := make([]TodoResponse, 0, len(page.Todos))
+	for _, todo := range page.Todos {
+		data = append(data, NewTodoResponse(todo))
+	}
+	return TodoListResponse{Data: data, NextCursor: page.NextCursor}
+}
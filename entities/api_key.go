@@ -0,0 +1,38 @@
+package entities
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey is a credential for service-to-service callers. Only a hash of the
+// key is stored; the key itself is shown once when it is minted or rotated.
+type APIKey struct {
+	ID   int    `gorm:"primaryKey"`
+	Name string `gorm:"size:100;not null"`
+	// Prefix is the start of the key, kept so a key can be recognised without storing it
+	Prefix string `gorm:"size:16;not null"`
+	Hash   string `gorm:"size:64;not null;uniqueIndex"`
+	// Subject is who the key acts as and owns the todos it creates
+	Subject string `gorm:"size:191;not null"`
+	// Scopes is the space separated list of granted scopes
+	Scopes     string `gorm:"size:255;not null"`
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// ScopeList returns the granted scopes
+func (k APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// Revoked reports whether the key has been revoked
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
@@ -9,9 +9,12 @@ import (
 // Todo represents the todo table structure
 type Todo struct {
 	gorm.Model
-	ID        int       `gorm:"primaryKey"`
-	Title     string    `gorm:"not null"`
-	Completed bool      `gorm:"default:false"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	ID          int       `gorm:"primaryKey"`
+	OwnerID     string    `gorm:"size:191;not null;default:'';index"`
+	Title       string    `gorm:"not null"`
+	Description string    `gorm:"type:text"`
+	Completed   bool      `gorm:"default:false"`
+	Version     int       `gorm:"not null;default:1"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
 }
@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func probe(err error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return err
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("all up", func(t *testing.T) {
+		report := Run(ctx, []Check{
+			{Name: "db", Critical: true, Probe: probe(nil)},
+			{Name: "cache", Probe: probe(nil)},
+		})
+
+		assert.Equal(t, StatusUp, report.Status)
+		assert.Len(t, report.Checks, 2)
+		assert.Equal(t, StatusUp, report.Checks["db"].Status)
+	})
+
+	t.Run("non-critical failure degrades", func(t *testing.T) {
+		report := Run(ctx, []Check{
+			{Name: "db", Critical: true, Probe: probe(nil)},
+			{Name: "cache", Probe: probe(errors.New("connection refused"))},
+		})
+
+		assert.Equal(t, StatusDegraded, report.Status)
+		assert.Equal(t, StatusDown, report.Checks["cache"].Status)
+		assert.Equal(t, "connection refused", report.Checks["cache"].Error)
+	})
+
+	t.Run("critical failure is down", func(t *testing.T) {
+		report := Run(ctx, []Check{
+			{Name: "db", Critical: true, Probe: probe(errors.New("connection refused"))},
+			{Name: "cache", Probe: probe(errors.New("connection refused"))},
+		})
+
+		assert.Equal(t, StatusDown, report.Status)
+		assert.True(t, report.Checks["db"].Critical)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		hang := func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}
+
+		start := time.Now()
+		report := Run(ctx, []Check{{Name: "slow", Critical: true, Timeout: 20 * time.Millisecond, Probe: hang}})
+
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		report := Run(ctx, []Check{{Name: "broken", Critical: true, Probe: func(ctx context.Context) error {
+			panic("boom")
+		}}})
+
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Contains(t, report.Checks["broken"].Error, "boom")
+	})
+}
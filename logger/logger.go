@@ -1,8 +1,95 @@
 package logger
 
+import "context"
+
+// Logger is a leveled logger. The plain methods join their arguments, the f
+// variants format printf style and the w variants take a message followed by
+// alternating keys and values, e.g. Infow("todo created", "id", 42).
 type Logger interface {
-	Info(args ...interface{})
 	Debug(args ...interface{})
+	Info(args ...interface{})
+	Warn(args ...interface{})
 	Error(args ...interface{})
 	Fatal(args ...interface{})
+
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+	Fatalw(msg string, keysAndValues ...interface{})
+
+	// With returns a child logger that adds the given key/value pairs to every entry
+	With(keysAndValues ...interface{}) Logger
+	// FromContext returns the request scoped logger stored in ctx, or a child
+	// carrying the request and user ids found in ctx
+	FromContext(ctx context.Context) Logger
+}
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+	userIDKey
+)
+
+// Field names used for the ids carried in the context
+const (
+	RequestIDField = "request_id"
+	UserIDField    = "user_id"
+)
+
+// NewContext returns a copy of ctx carrying the request scoped logger l
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// WithRequestID returns a copy of ctx carrying the request id
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request id stored in ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithUserID returns a copy of ctx carrying the id of the authenticated user
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// UserIDFromContext returns the user id stored in ctx, if any
+func UserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey).(string)
+	return userID
+}
+
+// fromContext implements Logger.FromContext on top of With for every implementation
+func fromContext(l Logger, ctx context.Context) Logger {
+	if ctx == nil {
+		return l
+	}
+	if scoped, ok := ctx.Value(loggerKey).(Logger); ok {
+		return scoped
+	}
+
+	var fields []interface{}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		fields = append(fields, RequestIDField, requestID)
+	}
+	if userID := UserIDFromContext(ctx); userID != "" {
+		fields = append(fields, UserIDField, userID)
+	}
+	if len(fields) == 0 {
+		return l
+	}
+	return l.With(fields...)
 }
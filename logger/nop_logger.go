@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"context"
+	"os"
+)
+
+// NopLogger discards every entry. Fatal variants still exit the process.
+type NopLogger struct{}
+
+// NewNopLogger creates a logger that discards everything
+func NewNopLogger() *NopLogger {
+	return &NopLogger{}
+}
+
+func (NopLogger) Debug(args ...interface{}) {}
+func (NopLogger) Info(args ...interface{})  {}
+func (NopLogger) Warn(args ...interface{})  {}
+func (NopLogger) Error(args ...interface{}) {}
+func (NopLogger) Fatal(args ...interface{}) { os.Exit(1) }
+
+func (NopLogger) Debugf(format string, args ...interface{}) {}
+func (NopLogger) Infof(format string, args ...interface{})  {}
+func (NopLogger) Warnf(format string, args ...interface{})  {}
+func (NopLogger) Errorf(format string, args ...interface{}) {}
+func (NopLogger) Fatalf(format string, args ...interface{}) { os.Exit(1) }
+
+func (NopLogger) Debugw(msg string, keysAndValues ...interface{}) {}
+func (NopLogger) Infow(msg string, keysAndValues ...interface{})  {}
+func (NopLogger) Warnw(msg string, keysAndValues ...interface{})  {}
+func (NopLogger) Errorw(msg string, keysAndValues ...interface{}) {}
+func (NopLogger) Fatalw(msg string, keysAndValues ...interface{}) { os.Exit(1) }
+
+func (l NopLogger) With(keysAndValues ...interface{}) Logger { return l }
+func (l NopLogger) FromContext(ctx context.Context) Logger   { return l }
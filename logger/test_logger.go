@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Level names the severity of a captured entry
+type Level string
+
+const (
+	DebugLevel Level = "debug"
+	InfoLevel  Level = "info"
+	WarnLevel  Level = "warn"
+	ErrorLevel Level = "error"
+	FatalLevel Level = "fatal"
+)
+
+// Entry is a log entry captured by TestLogger
+type Entry struct {
+	Level   Level
+	Message string
+	Fields  map[string]interface{}
+}
+
+// TestLogger records entries in memory so tests can assert on them. Child
+// loggers created with With share the parent's entries. Fatal variants record
+// the entry and then panic instead of exiting the test binary.
+type TestLogger struct {
+	fields []interface{}
+	sink   *testSink
+}
+
+type testSink struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewTestLogger creates an empty capturing logger
+func NewTestLogger() *TestLogger {
+	return &TestLogger{sink: &testSink{}}
+}
+
+// Entries returns a copy of every entry captured so far
+func (l *TestLogger) Entries() []Entry {
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+
+	return append([]Entry(nil), l.sink.entries...)
+}
+
+func (l *TestLogger) log(level Level, msg string, keysAndValues []interface{}) {
+	fields := make(map[string]interface{}, (len(l.fields)+len(keysAndValues))/2)
+	for _, kv := range [][]interface{}{l.fields, keysAndValues} {
+		for i := 0; i < len(kv); i += 2 {
+			var value interface{}
+			if i+1 < len(kv) {
+				value = kv[i+1]
+			}
+			fields[fmt.Sprint(kv[i])] = value
+		}
+	}
+
+	l.sink.mu.Lock()
+	l.sink.entries = append(l.sink.entries, Entry{Level: level, Message: msg, Fields: fields})
+	l.sink.mu.Unlock()
+
+	if level == FatalLevel {
+		panic("fatal: " + msg)
+	}
+}
+
+func (l *TestLogger) Debug(args ...interface{}) { l.log(DebugLevel, fmt.Sprint(args...), nil) }
+func (l *TestLogger) Info(args ...interface{})  { l.log(InfoLevel, fmt.Sprint(args...), nil) }
+func (l *TestLogger) Warn(args ...interface{})  { l.log(WarnLevel, fmt.Sprint(args...), nil) }
+func (l *TestLogger) Error(args ...interface{}) { l.log(ErrorLevel, fmt.Sprint(args...), nil) }
+func (l *TestLogger) Fatal(args ...interface{}) { l.log(FatalLevel, fmt.Sprint(args...), nil) }
+
+func (l *TestLogger) Debugf(format string, args ...interface{}) {
+	l.log(DebugLevel, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *TestLogger) Infof(format string, args ...interface{}) {
+	l.log(InfoLevel, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *TestLogger) Warnf(format string, args ...interface{}) {
+	l.log(WarnLevel, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *TestLogger) Errorf(format string, args ...interface{}) {
+	l.log(ErrorLevel, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *TestLogger) Fatalf(format string, args ...interface{}) {
+	l.log(FatalLevel, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *TestLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.log(DebugLevel, msg, keysAndValues)
+}
+
+func (l *TestLogger) Infow(msg string, keysAndValues ...interface{}) {
+	l.log(InfoLevel, msg, keysAndValues)
+}
+
+func (l *TestLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.log(WarnLevel, msg, keysAndValues)
+}
+
+func (l *TestLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.log(ErrorLevel, msg, keysAndValues)
+}
+
+func (l *TestLogger) Fatalw(msg string, keysAndValues ...interface{}) {
+	l.log(FatalLevel, msg, keysAndValues)
+}
+
+// With returns a child logger sharing the captured entries
+func (l *TestLogger) With(keysAndValues ...interface{}) Logger {
+	fields := append(append([]interface{}(nil), l.fields...), keysAndValues...)
+	return &TestLogger{fields: fields, sink: l.sink}
+}
+
+// FromContext returns the request scoped logger for ctx
+func (l *TestLogger) FromContext(ctx context.Context) Logger {
+	return fromContext(l, ctx)
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"log"
 
 	"go.uber.org/zap"
@@ -17,31 +18,45 @@ type ZapLogger struct {
 }
 
 func NewZapLogger(config *config.Config) *ZapLogger {
-	var logger *zap.Logger
-	var err error
+	var zapConfig zap.Config
 
 	// Use the app mode from the config
-	appMode := config.AppMode
-
-	if appMode == "production" {
+	if config.AppMode == "production" {
 		// Production config with JSON encoding
-		zapConfig := zap.NewProductionConfig()
-		zapConfig.EncoderConfig.TimeKey = "timestamp"
-		zapConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-		logger, err = zapConfig.Build()
+		zapConfig = zap.NewProductionConfig()
 	} else {
 		// Development config with console encoding
-		zapConfig := zap.NewDevelopmentConfig()
+		zapConfig = zap.NewDevelopmentConfig()
 		zapConfig.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-		zapConfig.EncoderConfig.TimeKey = "timestamp"
-		zapConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-		logger, err = zapConfig.Build()
+	}
+	zapConfig.EncoderConfig.TimeKey = "timestamp"
+	zapConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	// LOG_LEVEL and LOG_FORMAT override the defaults of the mode
+	if config.LogLevel != "" {
+		level, err := zap.ParseAtomicLevel(config.LogLevel)
+		if err != nil {
+			log.Fatalf("invalid log level: %v", err)
+		}
+		zapConfig.Level = level
+	}
+	switch config.LogFormat {
+	case "json":
+		zapConfig.Encoding = "json"
+		zapConfig.EncoderConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
+	case "console":
+		zapConfig.Encoding = "console"
 	}
 
+	logger, err := zapConfig.Build()
 	if err != nil {
 		log.Fatalf("failed to initialize logger: %v", err)
 	}
 
+	return newZapLogger(config, logger)
+}
+
+func newZapLogger(config *config.Config, logger *zap.Logger) *ZapLogger {
 	return &ZapLogger{
 		Config: config,
 		logger: logger,
@@ -58,14 +73,19 @@ func (l *ZapLogger) PreDestroy() {
 	}
 }
 
+// Debug logs messages at DEBUG level
+func (l *ZapLogger) Debug(args ...interface{}) {
+	l.sugar.Debug(args...)
+}
+
 // Info logs messages at INFO level
 func (l *ZapLogger) Info(args ...interface{}) {
 	l.sugar.Info(args...)
 }
 
-// Debug logs messages at DEBUG level
-func (l *ZapLogger) Debug(args ...interface{}) {
-	l.sugar.Debug(args...)
+// Warn logs messages at WARN level
+func (l *ZapLogger) Warn(args ...interface{}) {
+	l.sugar.Warn(args...)
 }
 
 // Error logs messages at ERROR level
@@ -78,6 +98,71 @@ func (l *ZapLogger) Fatal(args ...interface{}) {
 	l.sugar.Fatal(args...)
 }
 
+// Debugf logs a formatted message at DEBUG level
+func (l *ZapLogger) Debugf(format string, args ...interface{}) {
+	l.sugar.Debugf(format, args...)
+}
+
+// Infof logs a formatted message at INFO level
+func (l *ZapLogger) Infof(format string, args ...interface{}) {
+	l.sugar.Infof(format, args...)
+}
+
+// Warnf logs a formatted message at WARN level
+func (l *ZapLogger) Warnf(format string, args ...interface{}) {
+	l.sugar.Warnf(format, args...)
+}
+
+// Errorf logs a formatted message at ERROR level
+func (l *ZapLogger) Errorf(format string, args ...interface{}) {
+	l.sugar.Errorf(format, args...)
+}
+
+// Fatalf logs a formatted message at FATAL level and then calls os.Exit(1)
+func (l *ZapLogger) Fatalf(format string, args ...interface{}) {
+	l.sugar.Fatalf(format, args...)
+}
+
+// Debugw logs a message with key/value fields at DEBUG level
+func (l *ZapLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.sugar.Debugw(msg, keysAndValues...)
+}
+
+// Infow logs a message with key/value fields at INFO level
+func (l *ZapLogger) Infow(msg string, keysAndValues ...interface{}) {
+	l.sugar.Infow(msg, keysAndValues...)
+}
+
+// Warnw logs a message with key/value fields at WARN level
+func (l *ZapLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.sugar.Warnw(msg, keysAndValues...)
+}
+
+// Errorw logs a message with key/value fields at ERROR level
+func (l *ZapLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.sugar.Errorw(msg, keysAndValues...)
+}
+
+// Fatalw logs a message with key/value fields at FATAL level and then calls os.Exit(1)
+func (l *ZapLogger) Fatalw(msg string, keysAndValues ...interface{}) {
+	l.sugar.Fatalw(msg, keysAndValues...)
+}
+
+// With returns a child logger sharing the same core with extra fields
+func (l *ZapLogger) With(keysAndValues ...interface{}) Logger {
+	sugar := l.sugar.With(keysAndValues...)
+	return &ZapLogger{
+		Config: l.Config,
+		logger: sugar.Desugar(),
+		sugar:  sugar,
+	}
+}
+
+// FromContext returns the request scoped logger for ctx
+func (l *ZapLogger) FromContext(ctx context.Context) Logger {
+	return fromContext(l, ctx)
+}
+
 // GetLogger returns the initialized Zap logger instance
 func (l *ZapLogger) GetLogger() *zap.Logger {
 	return l.logger
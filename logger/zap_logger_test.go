@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func setupObservedLogger() (*ZapLogger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return newZapLogger(nil, zap.New(core)), logs
+}
+
+func TestZapLogger(t *testing.T) {
+	t.Run("formatted", func(t *testing.T) {
+		l, logs := setupObservedLogger()
+		l.Warnf("failed after %d attempts: %v", 3, "timeout")
+
+		entries := logs.All()
+		assert.Len(t, entries, 1)
+		assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+		assert.Equal(t, "failed after 3 attempts: timeout", entries[0].Message)
+	})
+
+	t.Run("structured with child fields", func(t *testing.T) {
+		l, logs := setupObservedLogger()
+		l.With("component", "todos").Infow("todo created", "id", 42)
+
+		entries := logs.All()
+		assert.Len(t, entries, 1)
+		assert.Equal(t, map[string]interface{}{"component": "todos", "id": int64(42)}, entries[0].ContextMap())
+	})
+
+	t.Run("from context", func(t *testing.T) {
+		l, logs := setupObservedLogger()
+		ctx := WithUserID(WithRequestID(context.Background(), "req-1"), "user-7")
+		l.FromContext(ctx).Info("hello")
+
+		entries := logs.All()
+		assert.Len(t, entries, 1)
+		assert.Equal(t, map[string]interface{}{RequestIDField: "req-1", UserIDField: "user-7"}, entries[0].ContextMap())
+	})
+
+	t.Run("request scoped logger wins", func(t *testing.T) {
+		l, logs := setupObservedLogger()
+		ctx := NewContext(context.Background(), l.With("scoped", true))
+		l.FromContext(ctx).Info("hello")
+
+		assert.Equal(t, map[string]interface{}{"scoped": true}, logs.All()[0].ContextMap())
+	})
+}
+
+func TestTestLogger(t *testing.T) {
+	l := NewTestLogger()
+	l.With("request_id", "req-1").Errorw("boom", "status", 500)
+	l.Infof("%d todos", 2)
+
+	assert.Equal(t, []Entry{
+		{Level: ErrorLevel, Message: "boom", Fields: map[string]interface{}{"request_id": "req-1", "status": 500}},
+		{Level: InfoLevel, Message: "2 todos", Fields: map[string]interface{}{}},
+	}, l.Entries())
+
+	assert.Panics(t, func() { l.Fatal("bye") })
+	assert.Equal(t, FatalLevel, l.Entries()[2].Level)
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+// ProblemContentType is the media type for RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body extended with a stable error code
+type Problem struct {
+	Type     string                 `json:"type"`
+	Title    string                 `json:"title"`
+	Status   int                    `json:"status"`
+	Detail   string                 `json:"detail,omitempty"`
+	Instance string                 `json:"instance,omitempty"`
+	Code     apperrors.Code         `json:"code"`
+	Errors   []apperrors.FieldError `json:"errors,omitempty"`
+}
+
+// statusByCode maps each domain error code onto its HTTP status
+var statusByCode = map[apperrors.Code]int{
+	apperrors.CodeNotFound:           http.StatusNotFound,
+	apperrors.CodeConflict:           http.StatusConflict,
+	apperrors.CodePreconditionFailed: http.StatusPreconditionFailed,
+	apperrors.CodeValidation:         http.StatusBadRequest,
+	apperrors.CodeUnauthenticated:    http.StatusUnauthorized,
+	apperrors.CodeForbidden:          http.StatusForbidden,
+	apperrors.CodeRateLimited:        http.StatusTooManyRequests,
+	apperrors.CodeUnavailable:        http.StatusServiceUnavailable,
+	apperrors.CodeInternal:           http.StatusInternalServerError,
+}
+
+// NewProblem builds the problem body for err. Messages of unclassified errors
+// are not exposed since they may leak internals.
+func NewProblem(ctx *gin.Context, err error) Problem {
+	code := apperrors.CodeOf(err)
+	status, ok := statusByCode[code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	problem := Problem{
+		Type:     "/problems/" + string(code),
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   http.StatusText(status),
+		Instance: ctx.Request.URL.Path,
+		Code:     code,
+	}
+
+	var appErr *apperrors.Error
+	if code != apperrors.CodeInternal && errors.As(err, &appErr) {
+		problem.Detail = appErr.Message
+		problem.Errors = appErr.Fields
+	}
+
+	return problem
+}
+
+// WriteProblem aborts the request with the given problem as the response
+func WriteProblem(ctx *gin.Context, problem Problem) {
+	ctx.Header("Content-Type", ProblemContentType)
+	ctx.AbortWithStatusJSON(problem.Status, problem)
+}
+
+// ErrorHandler renders errors attached to the gin context with ctx.Error as
+// problem responses, so handlers only have to record the error and return
+type ErrorHandler struct {
+	Component struct{}
+	Log       logger.Logger `autowired:"true"`
+}
+
+func (h *ErrorHandler) Handle(ctx *gin.Context) {
+	ctx.Next()
+
+	if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+		return
+	}
+
+	err := ctx.Errors.Last().Err
+	problem := NewProblem(ctx, err)
+	if problem.Status >= http.StatusInternalServerError {
+		h.Log.FromContext(ctx.Request.Context()).Errorw("Request failed",
+			"method", ctx.Request.Method,
+			"path", ctx.Request.URL.Path,
+			"error", err,
+		)
+	}
+
+	WriteProblem(ctx, problem)
+}
+
+// NoRoute renders unknown routes as not found problems
+func (h *ErrorHandler) NoRoute(ctx *gin.Context) {
+	WriteProblem(ctx, NewProblem(ctx, apperrors.NotFound("no route for %s %s", ctx.Request.Method, ctx.Request.URL.Path)))
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+func setupRouter() (*gin.Engine, *logger.TestLogger) {
+	gin.SetMode(gin.TestMode)
+	log := logger.NewTestLogger()
+
+	r := gin.New()
+	r.Use(
+		(&RequestLogger{Log: log}).Handle,
+		(&Recovery{Log: log}).Handle,
+		(&ErrorHandler{Log: log}).Handle,
+	)
+	r.GET("/ok/:id", func(ctx *gin.Context) {
+		// Handlers log through the request scoped logger
+		log.FromContext(ctx.Request.Context()).Info("handling")
+		ctx.String(http.StatusOK, "ok")
+	})
+	r.GET("/missing", func(ctx *gin.Context) {
+		ctx.Error(apperrors.NotFound("nothing here"))
+	})
+	r.GET("/panic", func(ctx *gin.Context) {
+		panic("boom")
+	})
+	r.GET("/abort", func(ctx *gin.Context) {
+		panic(http.ErrAbortHandler)
+	})
+	return r, log
+}
+
+func TestRequestLogger(t *testing.T) {
+	t.Run("generates a request id", func(t *testing.T) {
+		r, log := setupRouter()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ok/1", nil)
+		r.ServeHTTP(w, req)
+
+		requestID := w.Header().Get(RequestIDHeader)
+		assert.Len(t, requestID, 32)
+
+		entries := log.Entries()
+		assert.Len(t, entries, 2)
+		assert.Equal(t, "handling", entries[0].Message)
+		assert.Equal(t, requestID, entries[0].Fields[logger.RequestIDField])
+
+		access := entries[1]
+		assert.Equal(t, logger.InfoLevel, access.Level)
+		assert.Equal(t, requestID, access.Fields[logger.RequestIDField])
+		assert.Equal(t, http.MethodGet, access.Fields["method"])
+		assert.Equal(t, "/ok/:id", access.Fields["route"])
+		assert.Equal(t, "/ok/1", access.Fields["path"])
+		assert.Equal(t, http.StatusOK, access.Fields["status"])
+		assert.Equal(t, 2, access.Fields["bytes"])
+		assert.Contains(t, access.Fields, "latency")
+		assert.Contains(t, access.Fields, "client_ip")
+	})
+
+	t.Run("propagates a client request id", func(t *testing.T) {
+		r, log := setupRouter()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ok/1", nil)
+		req.Header.Set(RequestIDHeader, "client-id-1")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, "client-id-1", w.Header().Get(RequestIDHeader))
+		assert.Equal(t, "client-id-1", log.Entries()[1].Fields[logger.RequestIDField])
+	})
+
+	t.Run("replaces an invalid request id", func(t *testing.T) {
+		r, _ := setupRouter()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ok/1", nil)
+		req.Header.Set(RequestIDHeader, "has spaces\tand tabs")
+		r.ServeHTTP(w, req)
+
+		assert.NotEqual(t, "has spaces\tand tabs", w.Header().Get(RequestIDHeader))
+	})
+
+	t.Run("client errors are warnings", func(t *testing.T) {
+		r, log := setupRouter()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/missing", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		entries := log.Entries()
+		assert.Equal(t, logger.WarnLevel, entries[len(entries)-1].Level)
+	})
+}
+
+func TestRecovery(t *testing.T) {
+	r, log := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/panic", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	var problem Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, apperrors.CodeInternal, problem.Code)
+	assert.NotContains(t, problem.Detail, "boom")
+
+	entries := log.Entries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "Panic recovered", entries[0].Message)
+	assert.Equal(t, "boom", entries[0].Fields["panic"])
+	assert.Contains(t, entries[0].Fields["stack"], "runtime/debug.Stack")
+	assert.NotEmpty(t, entries[0].Fields[logger.RequestIDField])
+
+	// The access log still records the failed request
+	assert.Equal(t, logger.ErrorLevel, entries[1].Level)
+	assert.Equal(t, http.StatusInternalServerError, entries[1].Fields["status"])
+
+	// Aborted handlers are left to net/http, which drops the connection
+	r, log = setupRouter()
+	req, _ = http.NewRequest(http.MethodGet, "/abort", nil)
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	})
+	assert.Empty(t, log.Entries())
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+// Recovery turns a panicking handler into a 500 problem response and logs the
+// panic with its stack through the request scoped logger. http.ErrAbortHandler
+// is panicked again, so net/http aborts the response as the handler intended.
+type Recovery struct {
+	Component struct{}
+	Log       logger.Logger `autowired:"true"`
+}
+
+func (m *Recovery) Handle(ctx *gin.Context) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		if recovered == http.ErrAbortHandler {
+			panic(recovered)
+		}
+
+		m.Log.FromContext(ctx.Request.Context()).Errorw("Panic recovered",
+			"panic", fmt.Sprint(recovered),
+			"method", ctx.Request.Method,
+			"path", ctx.Request.URL.Path,
+			"stack", string(debug.Stack()),
+		)
+
+		if ctx.Writer.Written() {
+			ctx.Abort()
+			return
+		}
+		WriteProblem(ctx, NewProblem(ctx, fmt.Errorf("panic: %v", recovered)))
+	}()
+
+	ctx.Next()
+}
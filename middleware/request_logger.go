@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+// RequestIDHeader carries the correlation id between clients, this service and its logs
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client supplied ids so they can't bloat the logs
+const maxRequestIDLength = 128
+
+// RequestLogger assigns or propagates the request id, stores a request scoped
+// logger in the request context and writes one access log entry per request
+type RequestLogger struct {
+	Component struct{}
+	Log       logger.Logger `autowired:"true"`
+}
+
+func (m *RequestLogger) Handle(ctx *gin.Context) {
+	start := time.Now()
+
+	requestID := ctx.GetHeader(RequestIDHeader)
+	if !validRequestID(requestID) {
+		requestID = newRequestID()
+	}
+	ctx.Header(RequestIDHeader, requestID)
+
+	reqCtx := logger.WithRequestID(ctx.Request.Context(), requestID)
+	log := m.Log.FromContext(reqCtx)
+	ctx.Request = ctx.Request.WithContext(logger.NewContext(reqCtx, log))
+
+	ctx.Next()
+
+	status := ctx.Writer.Status()
+	fields := []interface{}{
+		"method", ctx.Request.Method,
+		"route", ctx.FullPath(),
+		"path", ctx.Request.URL.Path,
+		"status", status,
+		"latency", time.Since(start),
+		"bytes", ctx.Writer.Size(),
+		"client_ip", ctx.ClientIP(),
+	}
+
+	switch {
+	case status >= http.StatusInternalServerError:
+		log.Errorw("Request completed", fields...)
+	case status >= http.StatusBadRequest:
+		log.Warnw("Request completed", fields...)
+	default:
+		log.Infow("Request completed", fields...)
+	}
+}
+
+// validRequestID accepts short ids made of printable ASCII only
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+	"tuhuynh.com/go-ioc-gin-example/entities"
+)
+
+// APIKeyMigration handles the database schema for API keys
+func APIKeyMigration(db *gorm.DB) error {
+	return db.AutoMigrate(&entities.APIKey{})
+}
+
+// APIKeyMigrationDown drops the api_keys table
+func APIKeyMigrationDown(db *gorm.DB) error {
+	return db.Migrator().DropTable(&entities.APIKey{})
+}
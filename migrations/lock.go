@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockName identifies the migration lock shared by every replica
+const lockName = "schema_migrations"
+
+var errLockTimeout = errors.New("timed out waiting for the migration lock, another replica is migrating")
+
+// withLock runs fn on a single connection holding the database's migration
+// lock, so concurrent replicas migrate one at a time. MySQL uses GET_LOCK
+// and PostgreSQL an advisory lock; SQLite serializes writers by itself.
+func withLock(ctx context.Context, db *gorm.DB, timeout time.Duration, fn func(conn *gorm.DB) error) error {
+	return db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		// Start every statement afresh while keeping the pinned connection
+		conn = conn.Session(&gorm.Session{NewDB: true})
+		switch conn.Dialector.Name() {
+		case "mysql":
+			var acquired sql.NullInt64
+			if err := conn.Raw("SELECT GET_LOCK(?, ?)", lockName, int(timeout.Seconds())).Scan(&acquired).Error; err != nil {
+				return fmt.Errorf("failed to acquire the migration lock: %w", err)
+			}
+			if !acquired.Valid || acquired.Int64 != 1 {
+				return errLockTimeout
+			}
+			defer conn.Exec("SELECT RELEASE_LOCK(?)", lockName)
+		case "postgres":
+			lockCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			key := int64(crc32.ChecksumIEEE([]byte(lockName)))
+			if err := conn.WithContext(lockCtx).Exec("SELECT pg_advisory_lock(?)", key).Error; err != nil {
+				if lockCtx.Err() != nil {
+					return errLockTimeout
+				}
+				return fmt.Errorf("failed to acquire the migration lock: %w", err)
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", key)
+		}
+		return fn(conn)
+	})
+}
@@ -0,0 +1,208 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one numbered schema change. Up and Down run inside a
+// transaction, although MySQL commits DDL statements implicitly.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+	// Checksum identifies the applied change so edits to it are detected
+	Checksum string
+}
+
+// GoMigration wraps Go functions as a migration. Code can't be hashed, so the
+// checksum only covers its name.
+func GoMigration(version int64, name string, up, down func(tx *gorm.DB) error) Migration {
+	return Migration{Version: version, Name: name, Up: up, Down: down, Checksum: checksum("go:" + name)}
+}
+
+// schemaMigration records an applied migration in the schema_migrations table
+type schemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	Checksum  string    `gorm:"size:64;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// sqlFileName matches files such as 0003_index_todos.up.sql, or
+// 0003_index_todos.down.mysql.sql for a script specific to one dialect
+var sqlFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)(?:\.(mysql|postgres|sqlite))?\.sql$`)
+
+// sqlScripts are the variants of one script by dialect, "" being the one
+// for every dialect without its own
+type sqlScripts map[string]string
+
+// LoadSQL reads migrations from pairs of <version>_<name>.up.sql and
+// <version>_<name>.down.sql files. The down file is optional. Either may be
+// given for a single dialect as <version>_<name>.up.<dialect>.sql, which
+// then replaces the generic script on that dialect.
+func LoadSQL(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	ups := make(map[int64]sqlScripts)
+	downs := make(map[int64]sqlScripts)
+	for _, file := range files {
+		match := sqlFileName.FindStringSubmatch(path.Base(file))
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", file)
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", file, err)
+		}
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+			ups[version] = make(sqlScripts)
+			downs[version] = make(sqlScripts)
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			ups[version][match[4]] = string(content)
+		} else {
+			downs[version][match[4]] = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for version, m := range byVersion {
+		if len(ups[version]) == 0 {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		m.Up = ups[version].exec(*m)
+		m.Checksum = ups[version].checksum()
+		if len(downs[version]) > 0 {
+			m.Down = downs[version].exec(*m)
+		}
+		migrations = append(migrations, *m)
+	}
+	return migrations, nil
+}
+
+// exec runs each statement of the variant for the dialect of the database
+// in turn, as drivers don't accept several statements in one call by default
+func (s sqlScripts) exec(m Migration) func(tx *gorm.DB) error {
+	statements := make(map[string][]string, len(s))
+	for dialect, script := range s {
+		statements[dialect] = splitStatements(script)
+	}
+	return func(tx *gorm.DB) error {
+		dialect := tx.Dialector.Name()
+		script, ok := statements[dialect]
+		if !ok {
+			if script, ok = statements[""]; !ok {
+				return fmt.Errorf("migration %d_%s has no script for %s", m.Version, m.Name, dialect)
+			}
+		}
+		for _, statement := range script {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// checksum covers every variant, hashing a lone generic script as is
+func (s sqlScripts) checksum() string {
+	if generic, ok := s[""]; ok && len(s) == 1 {
+		return checksum(generic)
+	}
+	dialects := make([]string, 0, len(s))
+	for dialect := range s {
+		dialects = append(dialects, dialect)
+	}
+	sort.Strings(dialects)
+
+	var all strings.Builder
+	for _, dialect := range dialects {
+		fmt.Fprintf(&all, "-- %s\n%s", dialect, s[dialect])
+	}
+	return checksum(all.String())
+}
+
+// sortMigrations orders migrations by version and rejects duplicates
+func sortMigrations(migrations []Migration) error {
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return fmt.Errorf("duplicate migration version %d: %q and %q",
+				migrations[i].Version, migrations[i-1].Name, migrations[i].Name)
+		}
+	}
+	return nil
+}
+
+// splitStatements splits a script on semicolons ending a line, dropping
+// comment lines
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// sortedRows returns the applied migrations ordered by version
+func sortedRows(applied map[int64]schemaMigration) []schemaMigration {
+	rows := make([]schemaMigration, 0, len(applied))
+	for _, row := range applied {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Version < rows[j].Version
+	})
+	return rows
+}
@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+
+	"tuhuynh.com/go-ioc-gin-example/config"
+)
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+// goMigrations are the migrations written in Go. They share one sequence of
+// versions with the SQL migrations in sql/, so never reuse or renumber one
+// that has been released.
+func goMigrations(cfg *config.Config) []Migration {
+	return []Migration{
+		GoMigration(1, "create_todos", TodoMigration, TodoMigrationDown),
+		GoMigration(2, "create_api_keys", APIKeyMigration, APIKeyMigrationDown),
+		GoMigration(4, "assign_legacy_todos", AssignLegacyTodos(cfg.DBLegacyOwner), AssignLegacyTodosDown),
+	}
+}
+
+// All returns every migration ordered by version, configured by cfg
+func All(cfg *config.Config) ([]Migration, error) {
+	sqlDir, err := fs.Sub(sqlFiles, "sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := LoadSQL(sqlDir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations = append(migrations, goMigrations(cfg)...)
+	if err := sortMigrations(migrations); err != nil {
+		return nil, err
+	}
+	return migrations, nil
+}
@@ -1,36 +1,397 @@
 package migrations
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"gorm.io/gorm"
 	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/health"
 	"tuhuynh.com/go-ioc-gin-example/logger"
 )
 
-// Runner handles all database migrations
+const (
+	// defaultLockTimeout is how long to wait for another replica's migrations
+	defaultLockTimeout = time.Minute
+
+	// Failed migrations on boot are retried with backoff growing between these
+	initialRetryBackoff = time.Second
+	maxRetryBackoff     = time.Minute
+)
+
+// Migration states reported by Status
+const (
+	StateApplied  = "applied"
+	StatePending  = "pending"
+	StateModified = "modified" // applied, but changed since
+	StateMissing  = "missing"  // applied, but unknown to this build
+)
+
+// MigrationStatus describes one migration and whether it was applied
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	State     string
+	AppliedAt *time.Time
+}
+
+// Runner applies the versioned migrations, recording them in the
+// schema_migrations table
 type Runner struct {
 	Component struct{}
 	Log       logger.Logger  `autowired:"true"`
 	Config    *config.Config `autowired:"true"`
+
+	migrations []Migration
+	completed  atomic.Bool
+
+	mu      sync.Mutex
+	failure error
+	// retryBackoff overrides initialRetryBackoff in tests
+	retryBackoff time.Duration
 }
 
-// Run executes all migrations
-func (r *Runner) Run() error {
+func (r *Runner) PostConstruct() {
+	migrations, err := All(r.Config)
+	if err != nil {
+		r.Log.Fatalf("invalid migrations: %v", err)
+	}
+	r.migrations = migrations
+}
+
+// Boot prepares the schema as Config.MigrateOnBoot says before the server
+// starts. With verify-only it fails unless the schema matches this build, so
+// the server doesn't start. With auto the migrations run in the background,
+// retried with backoff until they succeed or ctx is done, and the server
+// stays unready until then.
+func (r *Runner) Boot(ctx context.Context) error {
+	switch r.Config.MigrateOnBoot {
+	case config.MigrateOnBootOff:
+		r.Log.Info("Skipping database migrations on boot")
+	case config.MigrateOnBootVerifyOnly:
+		if err := r.verifyOnce(ctx); err != nil {
+			return fmt.Errorf("database schema doesn't match this build, apply the migrations first: %w", err)
+		}
+	default:
+		go r.runUntilDone(ctx)
+	}
+	return nil
+}
+
+// runUntilDone runs the migrations until they succeed or ctx is done,
+// doubling the pause between attempts. Readiness reports the last failure.
+func (r *Runner) runUntilDone(ctx context.Context) {
+	backoff := r.retryBackoff
+	if backoff == 0 {
+		backoff = initialRetryBackoff
+	}
+
+	for {
+		err := r.Run(ctx)
+		r.mu.Lock()
+		r.failure = err
+		r.mu.Unlock()
+		if err == nil {
+			return
+		}
+
+		r.Log.Errorw("Database migrations failed, retrying", "error", err, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
+
+// Run applies every pending migration and marks the service ready
+func (r *Runner) Run(ctx context.Context) error {
 	r.Log.Info("Starting database migrations...")
 
-	db := r.Config.DB
+	if err := r.Up(ctx); err != nil {
+		return err
+	}
+
+	r.completed.Store(true)
+	r.Log.Info("All migrations completed successfully")
+	return nil
+}
+
+// Verify fails unless every migration of this build was applied unmodified.
+// Migrations applied by a newer build are fine.
+func (r *Runner) Verify(ctx context.Context) error {
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var current, expected int64
+	var pending, modified []string
+	for _, s := range statuses {
+		switch s.State {
+		case StatePending:
+			pending = append(pending, fmt.Sprintf("%d_%s", s.Version, s.Name))
+		case StateModified:
+			modified = append(modified, fmt.Sprintf("%d_%s", s.Version, s.Name))
+		}
+		if s.State != StateMissing {
+			expected = max(expected, s.Version)
+		}
+		if s.State != StatePending {
+			current = max(current, s.Version)
+		}
+	}
 
-	// Add all migrations here
-	migrations := []func(*gorm.DB) error{
-		TodoMigration,
+	if len(modified) > 0 {
+		return fmt.Errorf("applied migrations were modified: %v", modified)
 	}
+	if len(pending) > 0 {
+		return fmt.Errorf("schema is at version %d but this build expects %d, pending migrations: %v", current, expected, pending)
+	}
+	return nil
+}
 
-	// Execute each migration
-	for _, migration := range migrations {
-		if err := migration(db); err != nil {
+// verifyOnce verifies the schema until it matches, after which it is never
+// checked again
+func (r *Runner) verifyOnce(ctx context.Context) error {
+	if r.completed.Load() {
+		return nil
+	}
+	if err := r.Verify(ctx); err != nil {
+		return err
+	}
+	r.completed.Store(true)
+	r.Log.Info("Database schema matches this build")
+	return nil
+}
+
+// Up applies every pending migration in version order
+func (r *Runner) Up(ctx context.Context) error {
+	return r.locked(ctx, func(db *gorm.DB, applied map[int64]schemaMigration) error {
+		for _, m := range r.migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := r.apply(db, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down reverts the last n applied migrations, newest first
+func (r *Runner) Down(ctx context.Context, n int) error {
+	return r.locked(ctx, func(db *gorm.DB, applied map[int64]schemaMigration) error {
+		for _, m := range r.lastApplied(applied, n) {
+			if err := r.revert(db, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Redo reverts the last applied migration and applies it again
+func (r *Runner) Redo(ctx context.Context) error {
+	return r.locked(ctx, func(db *gorm.DB, applied map[int64]schemaMigration) error {
+		for _, m := range r.lastApplied(applied, 1) {
+			if err := r.revert(db, m); err != nil {
+				return err
+			}
+			if err := r.apply(db, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status lists every known migration, followed by applied ones this build
+// doesn't know
+func (r *Runner) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if r.Config.DB == nil {
+		return nil, errors.New("database not configured")
+	}
+	applied, err := r.applied(r.Config.DB.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(r.migrations))
+	known := make(map[int64]bool, len(r.migrations))
+	for _, m := range r.migrations {
+		known[m.Version] = true
+		status := MigrationStatus{Version: m.Version, Name: m.Name, State: StatePending}
+		if row, ok := applied[m.Version]; ok {
+			status.State = StateApplied
+			if row.Checksum != m.Checksum {
+				status.State = StateModified
+			}
+			status.AppliedAt = &row.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range sortedRows(applied) {
+		if !known[row.Version] {
+			statuses = append(statuses, MigrationStatus{Version: row.Version, Name: row.Name, State: StateMissing, AppliedAt: &row.AppliedAt})
+		}
+	}
+	return statuses, nil
+}
+
+// locked runs fn holding the migration lock, after checking that no applied
+// migration was changed since
+func (r *Runner) locked(ctx context.Context, fn func(db *gorm.DB, applied map[int64]schemaMigration) error) error {
+	if r.Config.DB == nil {
+		return errors.New("database not configured")
+	}
+
+	return withLock(ctx, r.Config.DB, defaultLockTimeout, func(db *gorm.DB) error {
+		if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+		applied, err := r.applied(db)
+		if err != nil {
 			return err
 		}
+		if err := r.verifyChecksums(applied); err != nil {
+			return err
+		}
+		return fn(db, applied)
+	})
+}
+
+// applied returns the rows of schema_migrations by version, none if the
+// table doesn't exist yet
+func (r *Runner) applied(db *gorm.DB) (map[int64]schemaMigration, error) {
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		return map[int64]schemaMigration{}, nil
 	}
 
-	r.Log.Info("All migrations completed successfully")
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	applied := make(map[int64]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// verifyChecksums fails when an applied migration no longer matches its checksum.
+// Applied migrations this build doesn't know are only logged, so rolling
+// back to an older build still starts.
+func (r *Runner) verifyChecksums(applied map[int64]schemaMigration) error {
+	known := make(map[int64]bool, len(r.migrations))
+	var modified []string
+	for _, m := range r.migrations {
+		known[m.Version] = true
+		if row, ok := applied[m.Version]; ok && row.Checksum != m.Checksum {
+			modified = append(modified, fmt.Sprintf("%d_%s", m.Version, m.Name))
+		}
+	}
+	if len(modified) > 0 {
+		return fmt.Errorf("applied migrations were modified: %v", modified)
+	}
+
+	for _, row := range sortedRows(applied) {
+		if !known[row.Version] {
+			r.Log.Warnw("Applied migration is unknown to this build", "version", row.Version, "name", row.Name)
+		}
+	}
+	return nil
+}
+
+// lastApplied returns up to n applied migrations, newest first. Reverting a
+// migration this build doesn't know fails, as its down step is missing.
+func (r *Runner) lastApplied(applied map[int64]schemaMigration, n int) []Migration {
+	byVersion := make(map[int64]Migration, len(r.migrations))
+	for _, m := range r.migrations {
+		byVersion[m.Version] = m
+	}
+
+	rows := sortedRows(applied)
+	var migrations []Migration
+	for i := len(rows) - 1; i >= 0 && len(migrations) < n; i-- {
+		m, ok := byVersion[rows[i].Version]
+		if !ok {
+			m = Migration{Version: rows[i].Version, Name: rows[i].Name}
+		}
+		migrations = append(migrations, m)
+	}
+	return migrations
+}
+
+func (r *Runner) apply(db *gorm.DB, m Migration) error {
+	r.Log.Infow("Applying migration", "version", m.Version, "name", m.Name)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := m.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&schemaMigration{
+			Version:   m.Version,
+			Name:      m.Name,
+			Checksum:  m.Checksum,
+			AppliedAt: time.Now().UTC(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+	}
 	return nil
 }
+
+func (r *Runner) revert(db *gorm.DB, m Migration) error {
+	if m.Down == nil {
+		return fmt.Errorf("migration %d_%s can't be reverted, it has no down step", m.Version, m.Name)
+	}
+	r.Log.Infow("Reverting migration", "version", m.Version, "name", m.Name)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := m.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&schemaMigration{}, m.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("reverting migration %d_%s failed: %w", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+// HealthChecks reports the service as not ready until the schema matches
+// this build, with the last failure while auto migrations are retried
+func (r *Runner) HealthChecks() []health.Check {
+	return []health.Check{
+		{
+			Name:     "migrations",
+			Critical: true,
+			Probe: func(ctx context.Context) error {
+				switch r.Config.MigrateOnBoot {
+				case config.MigrateOnBootOff:
+					return nil
+				case config.MigrateOnBootVerifyOnly:
+					return r.verifyOnce(ctx)
+				}
+
+				if r.completed.Load() {
+					return nil
+				}
+				r.mu.Lock()
+				defer r.mu.Unlock()
+				if r.failure != nil {
+					return r.failure
+				}
+				return errors.New("migrations have not completed")
+			},
+		},
+	}
+}
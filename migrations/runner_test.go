@@ -0,0 +1,303 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+var testSQL = fstest.MapFS{
+	"0002_create_tags.up.sql": {Data: []byte(`
+-- Tags are plain labels
+CREATE TABLE tags (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL
+);
+CREATE INDEX idx_tags_name ON tags (name);
+`)},
+	"0002_create_tags.down.sql": {Data: []byte("DROP TABLE tags;\n")},
+	"0003_seed_tags.up.sql":     {Data: []byte("INSERT INTO tags (name) VALUES ('home'), ('work');\n")},
+	"0003_seed_tags.down.sql":   {Data: []byte("DELETE FROM tags;\n")},
+}
+
+type note struct {
+	ID   int
+	Text string
+}
+
+func newTestRunner(t *testing.T) (*Runner, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// Every connection to :memory: is a new database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrations, err := LoadSQL(testSQL)
+	require.NoError(t, err)
+	migrations = append(migrations, GoMigration(1, "create_notes",
+		func(tx *gorm.DB) error { return tx.AutoMigrate(&note{}) },
+		func(tx *gorm.DB) error { return tx.Migrator().DropTable(&note{}) },
+	))
+	require.NoError(t, sortMigrations(migrations))
+
+	r := &Runner{Log: logger.NewTestLogger(), Config: &config.Config{DB: db}, migrations: migrations}
+	return r, db
+}
+
+func states(t *testing.T, r *Runner) []string {
+	statuses, err := r.Status(context.Background())
+	require.NoError(t, err)
+	var states []string
+	for _, s := range statuses {
+		states = append(states, s.State)
+	}
+	return states
+}
+
+func TestRunner(t *testing.T) {
+	ctx := context.Background()
+	r, db := newTestRunner(t)
+
+	assert.Equal(t, []string{StatePending, StatePending, StatePending}, states(t, r))
+
+	require.NoError(t, r.Run(ctx))
+	assert.Equal(t, []string{StateApplied, StateApplied, StateApplied}, states(t, r))
+	assert.NoError(t, r.HealthChecks()[0].Probe(ctx))
+	var tags int64
+	db.Table("tags").Count(&tags)
+	assert.Equal(t, int64(2), tags)
+
+	// Running again applies nothing
+	require.NoError(t, r.Up(ctx))
+	db.Table("tags").Count(&tags)
+	assert.Equal(t, int64(2), tags)
+
+	require.NoError(t, r.Down(ctx, 2))
+	assert.False(t, db.Migrator().HasTable("tags"))
+	assert.Equal(t, []string{StateApplied, StatePending, StatePending}, states(t, r))
+
+	require.NoError(t, r.Redo(ctx))
+	assert.True(t, db.Migrator().HasTable("notes"))
+	assert.Equal(t, []string{StateApplied, StatePending, StatePending}, states(t, r))
+
+	// Migrations applied by a newer build are reported but can't be reverted
+	require.NoError(t, r.Up(ctx))
+	r.migrations = r.migrations[:2]
+	assert.NoError(t, r.Up(ctx))
+	assert.Equal(t, []string{StateApplied, StateApplied, StateMissing}, states(t, r))
+	assert.ErrorContains(t, r.Down(ctx, 1), "no down step")
+}
+
+func TestRunnerChecksum(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRunner(t)
+	require.NoError(t, r.Up(ctx))
+
+	r.migrations[1].Checksum = checksum("edited")
+	assert.Equal(t, StateModified, states(t, r)[1])
+	assert.ErrorContains(t, r.Up(ctx), "2_create_tags")
+	assert.ErrorContains(t, r.Down(ctx, 1), "modified")
+}
+
+func TestRunnerFailedMigration(t *testing.T) {
+	ctx := context.Background()
+	r, db := newTestRunner(t)
+	broken, err := LoadSQL(fstest.MapFS{"0004_broken.up.sql": {Data: []byte("INSERT INTO tags (name) VALUES ('x');\nNOT SQL;\n")}})
+	require.NoError(t, err)
+	r.migrations = append(r.migrations, broken...)
+
+	assert.ErrorContains(t, r.Run(ctx), "4_broken")
+	assert.Error(t, r.HealthChecks()[0].Probe(ctx))
+
+	// The failed migration was rolled back and not recorded
+	var tags int64
+	db.Table("tags").Count(&tags)
+	assert.Equal(t, int64(2), tags)
+	assert.Equal(t, StatePending, states(t, r)[3])
+}
+
+func TestLoadSQL(t *testing.T) {
+	_, err := LoadSQL(fstest.MapFS{"create_tags.sql": {}})
+	assert.ErrorContains(t, err, "invalid migration file name")
+
+	_, err = LoadSQL(fstest.MapFS{"0001_tags.down.sql": {}})
+	assert.ErrorContains(t, err, "no up file")
+
+	_, err = LoadSQL(fstest.MapFS{"0001_tags.up.sql": {}, "0001_labels.down.sql": {}})
+	assert.ErrorContains(t, err, "named both")
+
+	migrations := []Migration{{Version: 1, Name: "a"}, {Version: 1, Name: "b"}}
+	assert.ErrorContains(t, sortMigrations(migrations), "duplicate migration version 1")
+
+	// The embedded migrations are valid
+	all, err := All(&config.Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), all[0].Version)
+}
+
+func TestLoadSQLDialects(t *testing.T) {
+	ctx := context.Background()
+	r, db := newTestRunner(t)
+	dialects, err := LoadSQL(fstest.MapFS{
+		"0004_label_tags.up.sql":             {Data: []byte("INSERT INTO tags (name) VALUES ('generic');\n")},
+		"0004_label_tags.up.sqlite.sql":      {Data: []byte("INSERT INTO tags (name) VALUES ('sqlite');\n")},
+		"0004_label_tags.down.mysql.sql":     {Data: []byte("DELETE FROM tags;\n")},
+		"0005_postgres_only.up.postgres.sql": {Data: []byte("SELECT 1;\n")},
+	})
+	require.NoError(t, err)
+	require.Len(t, dialects, 2)
+	require.NoError(t, sortMigrations(dialects))
+	assert.NotEqual(t, checksum("INSERT INTO tags (name) VALUES ('generic');\n"), dialects[0].Checksum)
+
+	r.migrations = append(r.migrations, dialects[0])
+	require.NoError(t, r.Up(ctx))
+	var names []string
+	db.Table("tags").Order("id").Pluck("name", &names)
+	assert.Equal(t, []string{"home", "work", "sqlite"}, names)
+	assert.ErrorContains(t, r.Down(ctx, 1), "4_label_tags has no script for sqlite")
+
+	r.migrations = append(r.migrations, dialects[1])
+	assert.ErrorContains(t, r.Up(ctx), "5_postgres_only has no script for sqlite")
+}
+
+// The embedded migrations apply and revert cleanly on SQLite
+func TestEmbeddedMigrations(t *testing.T) {
+	ctx := context.Background()
+	r, db := newTestRunner(t)
+	all, err := All(&config.Config{})
+	require.NoError(t, err)
+	r.migrations = all
+
+	require.NoError(t, r.Up(ctx))
+	assert.True(t, db.Migrator().HasIndex("todos", "idx_todos_owner_created"))
+	require.NoError(t, r.Down(ctx, len(all)))
+	assert.False(t, db.Migrator().HasTable("todos"))
+	require.NoError(t, r.Up(ctx))
+}
+
+func TestRunnerBoot(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("auto", func(t *testing.T) {
+		r, db := newTestRunner(t)
+		r.Config.MigrateOnBoot = config.MigrateOnBootAuto
+		probe := r.HealthChecks()[0].Probe
+		assert.ErrorContains(t, probe(ctx), "not completed")
+
+		require.NoError(t, r.Boot(ctx))
+		assert.Eventually(t, func() bool { return probe(ctx) == nil }, time.Second, 5*time.Millisecond)
+		assert.True(t, db.Migrator().HasTable("tags"))
+	})
+
+	t.Run("auto retrying", func(t *testing.T) {
+		r, db := newTestRunner(t)
+		r.Config.MigrateOnBoot = config.MigrateOnBootAuto
+		r.retryBackoff = 20 * time.Millisecond
+		probe := r.HealthChecks()[0].Probe
+
+		// The migration fails until the database recovers
+		var recovered atomic.Bool
+		r.migrations = append(r.migrations, GoMigration(4, "flaky",
+			func(tx *gorm.DB) error {
+				if !recovered.Load() {
+					return errors.New("database is read-only")
+				}
+				return tx.Exec("CREATE TABLE flaky (id INTEGER)").Error
+			}, nil,
+		))
+
+		ctx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+		require.NoError(t, r.Boot(ctx))
+		assert.Eventually(t, func() bool {
+			err := probe(ctx)
+			return err != nil && strings.Contains(err.Error(), "4_flaky")
+		}, time.Second, 5*time.Millisecond)
+
+		recovered.Store(true)
+		assert.Eventually(t, func() bool { return probe(ctx) == nil }, time.Second, 5*time.Millisecond)
+		assert.True(t, db.Migrator().HasTable("flaky"))
+	})
+
+	t.Run("off", func(t *testing.T) {
+		r, db := newTestRunner(t)
+		r.Config.MigrateOnBoot = config.MigrateOnBootOff
+
+		require.NoError(t, r.Boot(ctx))
+		assert.NoError(t, r.HealthChecks()[0].Probe(ctx))
+		assert.False(t, db.Migrator().HasTable("schema_migrations"))
+	})
+
+	t.Run("verify-only", func(t *testing.T) {
+		r, db := newTestRunner(t)
+		r.Config.MigrateOnBoot = config.MigrateOnBootVerifyOnly
+		probe := r.HealthChecks()[0].Probe
+
+		// Nothing is migrated and the server isn't started
+		err := r.Boot(ctx)
+		assert.EqualError(t, err, "database schema doesn't match this build, apply the migrations first: schema is at version 0 but this build expects 3, pending migrations: [1_create_notes 2_create_tags 3_seed_tags]")
+		assert.False(t, db.Migrator().HasTable("schema_migrations"))
+		assert.Error(t, probe(ctx))
+
+		// Until the migrations are applied elsewhere
+		require.NoError(t, r.Up(ctx))
+		assert.NoError(t, r.Boot(ctx))
+		assert.NoError(t, probe(ctx))
+	})
+}
+
+func TestRunnerVerify(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRunner(t)
+	require.NoError(t, r.Up(ctx))
+	assert.NoError(t, r.Verify(ctx))
+
+	// Migrations applied by a newer build don't matter
+	newer := r.migrations
+	r.migrations = r.migrations[:2]
+	assert.NoError(t, r.Verify(ctx))
+
+	r.migrations = newer
+	require.NoError(t, r.Down(ctx, 1))
+	assert.ErrorContains(t, r.Verify(ctx), "schema is at version 2 but this build expects 3")
+
+	r.migrations[0].Checksum = checksum("edited")
+	assert.ErrorContains(t, r.Verify(ctx), "modified: [1_create_notes]")
+}
+
+func TestAssignLegacyTodos(t *testing.T) {
+	ctx := context.Background()
+	r, db := newTestRunner(t)
+	all, err := All(&config.Config{})
+	require.NoError(t, err)
+
+	// A todo created before todos had owners
+	r.migrations = all[:3]
+	require.NoError(t, r.Up(ctx))
+	require.NoError(t, db.Exec("INSERT INTO todos (title, owner_id) VALUES ('legacy', '')").Error)
+
+	r.migrations = all
+	assert.ErrorContains(t, r.Up(ctx), "1 todos were created before todos had owners, set db.legacy_owner")
+
+	all, err = All(&config.Config{DBLegacyOwner: "alice"})
+	require.NoError(t, err)
+	r.migrations = all
+	require.NoError(t, r.Up(ctx))
+
+	var owner string
+	require.NoError(t, db.Raw("SELECT owner_id FROM todos WHERE title = 'legacy'").Scan(&owner).Error)
+	assert.Equal(t, "alice", owner)
+}
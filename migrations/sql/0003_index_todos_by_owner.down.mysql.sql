@@ -0,0 +1 @@
+DROP INDEX idx_todos_owner_created ON todos;
@@ -0,0 +1,2 @@
+-- Serves the owner scoped, newest first listing of todos
+CREATE INDEX idx_todos_owner_created ON todos (owner_id, created_at, id);
@@ -1,6 +1,8 @@
 package migrations
 
 import (
+	"fmt"
+
 	"gorm.io/gorm"
 	"tuhuynh.com/go-ioc-gin-example/entities"
 )
@@ -14,3 +16,35 @@ func TodoMigration(db *gorm.DB) error {
 
 	return nil
 }
+
+// TodoMigrationDown drops the todos table
+func TodoMigrationDown(db *gorm.DB) error {
+	return db.Migrator().DropTable(&entities.Todo{})
+}
+
+// AssignLegacyTodos returns the migration giving owner the todos created
+// before todos had owners. Their owner_id is empty, which no caller has, so
+// they can't be reached. Without an owner it fails while there are any, so
+// they aren't lost unnoticed.
+func AssignLegacyTodos(owner string) func(db *gorm.DB) error {
+	return func(db *gorm.DB) error {
+		if owner != "" {
+			return db.Exec("UPDATE todos SET owner_id = ? WHERE owner_id = ''", owner).Error
+		}
+
+		var n int64
+		if err := db.Table("todos").Where("owner_id = ''").Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("%d todos were created before todos had owners, set db.legacy_owner (DB_LEGACY_OWNER) to the subject they belong to", n)
+		}
+		return nil
+	}
+}
+
+// AssignLegacyTodosDown keeps the owners, as the todos that had none can't be
+// told apart anymore
+func AssignLegacyTodosDown(db *gorm.DB) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/entities"
+)
+
+// APIKeyRepository stores API keys. Keys are looked up by the hash of the
+// secret; revoked keys are kept so their use can still be audited.
+type APIKeyRepository interface {
+	Create(ctx context.Context, key entities.APIKey) (entities.APIKey, error)
+	Get(ctx context.Context, id int) (entities.APIKey, error)
+	GetByHash(ctx context.Context, hash string) (entities.APIKey, error)
+	List(ctx context.Context) ([]entities.APIKey, error)
+	// Rotate replaces the secret of a key that hasn't been revoked
+	Rotate(ctx context.Context, id int, prefix, hash string) (entities.APIKey, error)
+	Revoke(ctx context.Context, id int, at time.Time) error
+	TouchLastUsed(ctx context.Context, id int, at time.Time) error
+}
+
+func apiKeyNotFound(id int) error {
+	return apperrors.NotFound("api key %d not found", id)
+}
+
+func apiKeyRevoked(id int) error {
+	return apperrors.Conflict("api key %d has been revoked", id)
+}
@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/database"
+	"tuhuynh.com/go-ioc-gin-example/entities"
+)
+
+type APIKeyRepositoryMock struct {
+	Component struct{} `implements:"APIKeyRepository"`
+	Qualifier struct{} `value:"mock"`
+	keys      map[int]entities.APIKey
+	mutex     sync.RWMutex
+	lastID    int
+}
+
+// Initialize the mock repository with an empty map
+func (r *APIKeyRepositoryMock) init() {
+	if r.keys == nil {
+		r.keys = make(map[int]entities.APIKey)
+	}
+}
+
+func (r *APIKeyRepositoryMock) Create(ctx context.Context, key entities.APIKey) (entities.APIKey, error) {
+	r.init()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.keys {
+		if existing.Hash == key.Hash {
+			return entities.APIKey{}, apperrors.Conflict("api key conflicts with existing data")
+		}
+	}
+
+	r.lastID++
+	key.ID = r.lastID
+	now := time.Now()
+	key.CreatedAt = now
+	key.UpdatedAt = now
+	r.undo(ctx, key.ID)
+	r.keys[key.ID] = key
+	return key, nil
+}
+
+func (r *APIKeyRepositoryMock) Get(ctx context.Context, id int) (entities.APIKey, error) {
+	r.init()
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if key, exists := r.keys[id]; exists {
+		return key, nil
+	}
+	return entities.APIKey{}, apiKeyNotFound(id)
+}
+
+func (r *APIKeyRepositoryMock) GetByHash(ctx context.Context, hash string) (entities.APIKey, error) {
+	r.init()
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, key := range r.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return entities.APIKey{}, apperrors.NotFound("api key not found")
+}
+
+func (r *APIKeyRepositoryMock) List(ctx context.Context) ([]entities.APIKey, error) {
+	r.init()
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	keys := make([]entities.APIKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+func (r *APIKeyRepositoryMock) Rotate(ctx context.Context, id int, prefix, hash string) (entities.APIKey, error) {
+	r.init()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return entities.APIKey{}, apiKeyNotFound(id)
+	}
+	if key.Revoked() {
+		return entities.APIKey{}, apiKeyRevoked(id)
+	}
+
+	key.Prefix = prefix
+	key.Hash = hash
+	key.LastUsedAt = nil
+	key.UpdatedAt = time.Now()
+	r.undo(ctx, id)
+	r.keys[id] = key
+	return key, nil
+}
+
+func (r *APIKeyRepositoryMock) Revoke(ctx context.Context, id int, at time.Time) error {
+	r.init()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return apiKeyNotFound(id)
+	}
+	if !key.Revoked() {
+		key.RevokedAt = &at
+		r.undo(ctx, id)
+		r.keys[id] = key
+	}
+	return nil
+}
+
+func (r *APIKeyRepositoryMock) TouchLastUsed(ctx context.Context, id int, at time.Time) error {
+	r.init()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if key, exists := r.keys[id]; exists {
+		key.LastUsedAt = &at
+		r.undo(ctx, id)
+		r.keys[id] = key
+	}
+	return nil
+}
+
+// undo puts the key with the given id back as it is now, or removes it if
+// there is none, when the transaction of ctx rolls back. The caller must hold
+// r.mutex.
+func (r *APIKeyRepositoryMock) undo(ctx context.Context, id int) {
+	previous, existed := r.keys[id]
+	database.OnRollback(ctx, func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		if existed {
+			r.keys[id] = previous
+		} else {
+			delete(r.keys, id)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/entities"
+)
+
+type APIKeyRepositorySql struct {
+	Component struct{}       `implements:"APIKeyRepository"`
+	Qualifier struct{}       `value:"sql"`
+	Config    *config.Config `autowired:"true"`
+}
+
+func (r *APIKeyRepositorySql) Create(ctx context.Context, key entities.APIKey) (entities.APIKey, error) {
+	err := r.Config.DB.WithContext(ctx).Create(&key).Error
+	return key, translateError(err)
+}
+
+func (r *APIKeyRepositorySql) Get(ctx context.Context, id int) (entities.APIKey, error) {
+	var key entities.APIKey
+	result := r.Config.DB.WithContext(ctx).First(&key, id)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return key, apiKeyNotFound(id)
+	}
+	return key, translateError(result.Error)
+}
+
+func (r *APIKeyRepositorySql) GetByHash(ctx context.Context, hash string) (entities.APIKey, error) {
+	var key entities.APIKey
+	result := r.Config.DB.WithContext(ctx).Where("hash = ?", hash).First(&key)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return key, apperrors.NotFound("api key not found")
+	}
+	return key, translateError(result.Error)
+}
+
+func (r *APIKeyRepositorySql) List(ctx context.Context) ([]entities.APIKey, error) {
+	keys := make([]entities.APIKey, 0)
+	err := r.Config.DB.WithContext(ctx).Order("id").Find(&keys).Error
+	return keys, translateError(err)
+}
+
+func (r *APIKeyRepositorySql) Rotate(ctx context.Context, id int, prefix, hash string) (entities.APIKey, error) {
+	result := r.Config.DB.WithContext(ctx).Model(&entities.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"prefix": prefix, "hash": hash, "last_used_at": nil})
+	if result.Error != nil {
+		return entities.APIKey{}, translateError(result.Error)
+	}
+
+	key, err := r.Get(ctx, id)
+	if err != nil {
+		return key, err
+	}
+	if result.RowsAffected == 0 {
+		return entities.APIKey{}, apiKeyRevoked(id)
+	}
+	return key, nil
+}
+
+// Revoke marks the key as revoked; revoking it again keeps the original time
+func (r *APIKeyRepositorySql) Revoke(ctx context.Context, id int, at time.Time) error {
+	result := r.Config.DB.WithContext(ctx).Model(&entities.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		// Either already revoked, which is fine, or missing
+		_, err := r.Get(ctx, id)
+		return err
+	}
+	return nil
+}
+
+func (r *APIKeyRepositorySql) TouchLastUsed(ctx context.Context, id int, at time.Time) error {
+	err := r.Config.DB.WithContext(ctx).Model(&entities.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", at).Error
+	return translateError(err)
+}
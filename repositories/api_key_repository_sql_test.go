@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/entities"
+)
+
+func TestAPIKeyRepositorySql(t *testing.T) {
+	ctx := context.Background()
+	r := &APIKeyRepositorySql{Config: newTestConfig(t)}
+
+	key, err := r.Create(ctx, entities.APIKey{Name: "ci", Prefix: "ak_1", Hash: "hash-1", Subject: "service:ci", Scopes: "todos:read"})
+	require.NoError(t, err)
+	assert.NotZero(t, key.ID)
+
+	// Hashes are unique
+	_, err = r.Create(ctx, entities.APIKey{Name: "copy", Prefix: "ak_1", Hash: "hash-1", Subject: "service:ci"})
+	assert.ErrorIs(t, err, apperrors.ErrConflict)
+
+	found, err := r.GetByHash(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, found.ID)
+	_, err = r.GetByHash(ctx, "unknown")
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, r.TouchLastUsed(ctx, key.ID, at))
+	rotated, err := r.Rotate(ctx, key.ID, "ak_2", "hash-2")
+	require.NoError(t, err)
+	assert.Equal(t, "hash-2", rotated.Hash)
+	assert.Nil(t, rotated.LastUsedAt)
+
+	require.NoError(t, r.Revoke(ctx, key.ID, at))
+	require.NoError(t, r.Revoke(ctx, key.ID, at.Add(time.Hour)))
+	revoked, err := r.Get(ctx, key.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked.RevokedAt.Equal(at))
+
+	_, err = r.Rotate(ctx, key.ID, "ak_3", "hash-3")
+	assert.ErrorIs(t, err, apperrors.ErrConflict)
+	assert.ErrorIs(t, r.Revoke(ctx, 999, at), apperrors.ErrNotFound)
+
+	keys, err := r.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"gorm.io/gorm"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+)
+
+// translateError maps storage errors onto domain errors so callers never need
+// to know which repository implementation produced them
+func translateError(err error) error {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, new(*apperrors.Error)):
+		return err
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, sql.ErrNoRows):
+		return apperrors.Wrap(apperrors.CodeNotFound, err, "todo not found")
+	case errors.Is(err, gorm.ErrDuplicatedKey), errors.Is(err, gorm.ErrForeignKeyViolated):
+		return apperrors.Wrap(apperrors.CodeConflict, err, "todo conflicts with existing data")
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return apperrors.Unavailable(err, "database unavailable")
+	default:
+		return err
+	}
+}
+
+func todoNotFound(id int) error {
+	return apperrors.NotFound("todo %d not found", id)
+}
+
+func todoVersionMismatch(id int) error {
+	return apperrors.PreconditionFailed("todo %d has been modified since it was read", id)
+}
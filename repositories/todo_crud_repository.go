@@ -6,10 +6,18 @@ import (
 	"tuhuynh.com/go-ioc-gin-example/entities"
 )
 
+// TodoCrudRepository stores todos. Every operation is scoped to an owner:
+// List uses query.OwnerID, Create and Update use todo.OwnerID and the others
+// take it explicitly. Todos of other owners are reported as not found.
+//
+// Writes are version checked: Update uses todo.Version, Patch uses
+// patch.Version and Delete takes the version explicitly. A version of 0 skips
+// the check; a mismatch fails with apperrors.ErrPreconditionFailed.
 type TodoCrudRepository interface {
-	List(ctx context.Context) ([]entities.Todo, error)
-	Create(ctx context.Context, todo entities.Todo) error
-	Get(ctx context.Context, id int) (entities.Todo, error)
-	Update(ctx context.Context, todo entities.Todo) error
-	Delete(ctx context.Context, id int) error
+	List(ctx context.Context, query TodoListQuery) (TodoPage, error)
+	Create(ctx context.Context, todo entities.Todo) (entities.Todo, error)
+	Get(ctx context.Context, ownerID string, id int) (entities.Todo, error)
+	Update(ctx context.Context, todo entities.Todo) (entities.Todo, error)
+	Patch(ctx context.Context, ownerID string, id int, patch TodoPatch) (entities.Todo, error)
+	Delete(ctx context.Context, ownerID string, id int, version int) error
 }
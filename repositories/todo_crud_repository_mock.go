@@ -2,9 +2,12 @@ package repositories
 
 import (
 	"context"
-	"database/sql"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"tuhuynh.com/go-ioc-gin-example/database"
 	"tuhuynh.com/go-ioc-gin-example/entities"
 )
 
@@ -23,62 +26,169 @@ func (r *TodoCrudRepositoryMock) init() {
 	}
 }
 
-func (r *TodoCrudRepositoryMock) List(ctx context.Context) ([]entities.Todo, error) {
+func (r *TodoCrudRepositoryMock) List(ctx context.Context, query TodoListQuery) (TodoPage, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return TodoPage{}, err
+	}
+	cursor, err := query.DecodeCursor()
+	if err != nil {
+		return TodoPage{}, err
+	}
+
 	r.init()
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
+	column, desc := query.Sort.Column()
+	sortValue := func(todo entities.Todo) time.Time {
+		if column == "updated_at" {
+			return todo.UpdatedAt
+		}
+		return todo.CreatedAt
+	}
+	// after reports whether a comes after b in the requested order
+	after := func(a, b entities.Todo) bool {
+		av, bv := sortValue(a), sortValue(b)
+		if !av.Equal(bv) {
+			return av.After(bv) != desc
+		}
+		return (a.ID > b.ID) != desc
+	}
+
 	todos := make([]entities.Todo, 0, len(r.todos))
 	for _, todo := range r.todos {
+		if todo.OwnerID != query.OwnerID {
+			continue
+		}
+		if query.Completed != nil && todo.Completed != *query.Completed {
+			continue
+		}
+		if query.Search != "" && !strings.Contains(strings.ToLower(todo.Title), strings.ToLower(query.Search)) {
+			continue
+		}
+		if cursor != nil && !after(todo, entities.Todo{ID: cursor.ID, CreatedAt: cursor.Value, UpdatedAt: cursor.Value}) {
+			continue
+		}
 		todos = append(todos, todo)
 	}
-	return todos, nil
+
+	sort.Slice(todos, func(i, j int) bool {
+		return after(todos[j], todos[i])
+	})
+	if len(todos) > query.Limit+1 {
+		todos = todos[:query.Limit+1]
+	}
+
+	return newTodoPage(todos, query), nil
 }
 
-func (r *TodoCrudRepositoryMock) Create(ctx context.Context, todo entities.Todo) error {
+func (r *TodoCrudRepositoryMock) Create(ctx context.Context, todo entities.Todo) (entities.Todo, error) {
 	r.init()
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
 	r.lastID++
 	todo.ID = r.lastID
+	todo.Version = 1
+	now := time.Now()
+	todo.CreatedAt = now
+	todo.UpdatedAt = now
+	r.undo(ctx, todo.ID)
 	r.todos[todo.ID] = todo
-	return nil
+	return todo, nil
 }
 
-func (r *TodoCrudRepositoryMock) Get(ctx context.Context, id int) (entities.Todo, error) {
+func (r *TodoCrudRepositoryMock) Get(ctx context.Context, ownerID string, id int) (entities.Todo, error) {
 	r.init()
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	if todo, exists := r.todos[id]; exists {
-		return todo, nil
+	return r.lookup(ownerID, id, 0)
+}
+
+func (r *TodoCrudRepositoryMock) Update(ctx context.Context, todo entities.Todo) (entities.Todo, error) {
+	r.init()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, err := r.lookup(todo.OwnerID, todo.ID, todo.Version)
+	if err != nil {
+		return entities.Todo{}, err
 	}
-	return entities.Todo{}, sql.ErrNoRows
+
+	existing.Title = todo.Title
+	existing.Description = todo.Description
+	existing.Completed = todo.Completed
+	r.undo(ctx, existing.ID)
+	return r.save(existing), nil
 }
 
-func (r *TodoCrudRepositoryMock) Update(ctx context.Context, todo entities.Todo) error {
+func (r *TodoCrudRepositoryMock) Patch(ctx context.Context, ownerID string, id int, patch TodoPatch) (entities.Todo, error) {
 	r.init()
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if _, exists := r.todos[todo.ID]; !exists {
-		return sql.ErrNoRows
+	existing, err := r.lookup(ownerID, id, patch.Version)
+	if err != nil {
+		return entities.Todo{}, err
 	}
 
-	r.todos[todo.ID] = todo
-	return nil
+	// Like the SQL repository, an empty patch doesn't count as a write
+	if len(patch.columns()) == 0 {
+		return existing, nil
+	}
+	r.undo(ctx, id)
+	return r.save(patch.apply(existing)), nil
 }
 
-func (r *TodoCrudRepositoryMock) Delete(ctx context.Context, id int) error {
+func (r *TodoCrudRepositoryMock) Delete(ctx context.Context, ownerID string, id int, version int) error {
 	r.init()
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if _, exists := r.todos[id]; !exists {
-		return sql.ErrNoRows
+	if _, err := r.lookup(ownerID, id, version); err != nil {
+		return err
 	}
 
+	r.undo(ctx, id)
 	delete(r.todos, id)
 	return nil
 }
+
+// lookup finds a todo of the owner and checks it is at the expected version.
+// The caller must hold r.mutex.
+func (r *TodoCrudRepositoryMock) lookup(ownerID string, id int, version int) (entities.Todo, error) {
+	todo, exists := r.todos[id]
+	if !exists || todo.OwnerID != ownerID {
+		return entities.Todo{}, todoNotFound(id)
+	}
+	if version > 0 && version != todo.Version {
+		return entities.Todo{}, todoVersionMismatch(id)
+	}
+	return todo, nil
+}
+
+// save stores a modified todo, bumping its version. The caller must hold r.mutex.
+func (r *TodoCrudRepositoryMock) save(todo entities.Todo) entities.Todo {
+	todo.Version++
+	todo.UpdatedAt = time.Now()
+	r.todos[todo.ID] = todo
+	return todo
+}
+
+// undo puts the todo with the given id back as it is now, or removes it if
+// there is none, when the transaction of ctx rolls back. The caller must hold
+// r.mutex.
+func (r *TodoCrudRepositoryMock) undo(ctx context.Context, id int) {
+	previous, existed := r.todos[id]
+	database.OnRollback(ctx, func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		if existed {
+			r.todos[id] = previous
+		} else {
+			delete(r.todos, id)
+		}
+	})
+}
@@ -2,46 +2,162 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"gorm.io/gorm"
-	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/database"
 	"tuhuynh.com/go-ioc-gin-example/entities"
 )
 
+// TodoCrudRepositorySql stores todos in the database, reading from a
+// replica where the Resolver allows. Within TxManager.WithinTx it uses the
+// transaction of the context.
 type TodoCrudRepositorySql struct {
-	Component struct{}       `implements:"TodoCrudRepository"`
-	Qualifier struct{}       `value:"sql"`
-	Config    *config.Config `autowired:"true"`
+	Component struct{}           `implements:"TodoCrudRepository"`
+	Qualifier struct{}           `value:"sql"`
+	Resolver  *database.Resolver `autowired:"true"`
 }
 
-func (r *TodoCrudRepositorySql) List(ctx context.Context) ([]entities.Todo, error) {
-	var todos []entities.Todo
-	result := r.Config.DB.WithContext(ctx).Find(&todos)
-	return todos, result.Error
+func (r *TodoCrudRepositorySql) List(ctx context.Context, query TodoListQuery) (TodoPage, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return TodoPage{}, err
+	}
+	cursor, err := query.DecodeCursor()
+	if err != nil {
+		return TodoPage{}, err
+	}
+
+	db := r.Resolver.Reader(ctx).Where("owner_id = ?", query.OwnerID)
+	if query.Completed != nil {
+		db = db.Where("completed = ?", *query.Completed)
+	}
+	if query.Search != "" {
+		db = db.Where("LOWER(title) LIKE ? ESCAPE '!'", "%"+escapeLike(strings.ToLower(query.Search))+"%")
+	}
+
+	column, desc := query.Sort.Column()
+	op, dir := ">", "ASC"
+	if desc {
+		op, dir = "<", "DESC"
+	}
+	if cursor != nil {
+		// Keyset pagination: resume strictly after the last row of the previous page
+		db = db.Where(
+			fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, op),
+			cursor.Value, cursor.Value, cursor.ID,
+		)
+	}
+
+	// Fetch one extra row to know whether another page follows
+	todos := make([]entities.Todo, 0, query.Limit+1)
+	result := db.Order(fmt.Sprintf("%s %s, id %s", column, dir, dir)).
+		Limit(query.Limit + 1).
+		Find(&todos)
+	if result.Error != nil {
+		return TodoPage{}, translateError(result.Error)
+	}
+
+	return newTodoPage(todos, query), nil
+}
+
+func (r *TodoCrudRepositorySql) Create(ctx context.Context, todo entities.Todo) (entities.Todo, error) {
+	todo.Version = 1
+	err := r.Resolver.Writer(ctx).Create(&todo).Error
+	return todo, translateError(err)
 }
 
-func (r *TodoCrudRepositorySql) Create(ctx context.Context, todo entities.Todo) error {
-	return r.Config.DB.WithContext(ctx).Create(&todo).Error
+func (r *TodoCrudRepositorySql) Get(ctx context.Context, ownerID string, id int) (entities.Todo, error) {
+	return r.get(r.Resolver.Reader(ctx), ownerID, id)
 }
 
-func (r *TodoCrudRepositorySql) Get(ctx context.Context, id int) (entities.Todo, error) {
+func (r *TodoCrudRepositorySql) get(db *gorm.DB, ownerID string, id int) (entities.Todo, error) {
 	var todo entities.Todo
-	result := r.Config.DB.WithContext(ctx).First(&todo, id)
-	return todo, result.Error
+	result := db.Where("owner_id = ?", ownerID).First(&todo, id)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return todo, todoNotFound(id)
+	}
+	return todo, translateError(result.Error)
+}
+
+// Update replaces the editable fields of the todo identified by todo.ID.
+// Save is avoided on purpose since it inserts a new row when none matches.
+func (r *TodoCrudRepositorySql) Update(ctx context.Context, todo entities.Todo) (entities.Todo, error) {
+	return r.updateColumns(ctx, todo.OwnerID, todo.ID, todo.Version, map[string]interface{}{
+		"title":       todo.Title,
+		"description": todo.Description,
+		"completed":   todo.Completed,
+	})
+}
+
+func (r *TodoCrudRepositorySql) Patch(ctx context.Context, ownerID string, id int, patch TodoPatch) (entities.Todo, error) {
+	columns := patch.columns()
+	if len(columns) > 0 {
+		return r.updateColumns(ctx, ownerID, id, patch.Version, columns)
+	}
+
+	// Nothing to write, but the precondition still has to hold on the primary
+	todo, err := r.get(r.Resolver.Writer(ctx), ownerID, id)
+	if err != nil {
+		return todo, err
+	}
+	if patch.Version > 0 && patch.Version != todo.Version {
+		return entities.Todo{}, todoVersionMismatch(id)
+	}
+	return todo, nil
 }
 
-func (r *TodoCrudRepositorySql) Update(ctx context.Context, todo entities.Todo) error {
-	result := r.Config.DB.WithContext(ctx).Save(&todo)
+func (r *TodoCrudRepositorySql) Delete(ctx context.Context, ownerID string, id int, version int) error {
+	primary := r.Resolver.Writer(ctx)
+	db := primary.Where("id = ? AND owner_id = ?", id, ownerID)
+	if version > 0 {
+		db = db.Where("version = ?", version)
+	}
+
+	result := db.Delete(&entities.Todo{})
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
 	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+		return r.missingOrModified(primary, ownerID, id)
 	}
-	return result.Error
+	return nil
 }
 
-func (r *TodoCrudRepositorySql) Delete(ctx context.Context, id int) error {
-	result := r.Config.DB.WithContext(ctx).Delete(&entities.Todo{}, id)
+// updateColumns applies the assignments and bumps the version in a single
+// conditional UPDATE, then reads the row back
+func (r *TodoCrudRepositorySql) updateColumns(ctx context.Context, ownerID string, id int, version int, columns map[string]interface{}) (entities.Todo, error) {
+	columns["version"] = gorm.Expr("version + 1")
+
+	primary := r.Resolver.Writer(ctx)
+	db := primary.Model(&entities.Todo{}).Where("id = ? AND owner_id = ?", id, ownerID)
+	if version > 0 {
+		db = db.Where("version = ?", version)
+	}
+
+	result := db.Updates(columns)
+	if result.Error != nil {
+		return entities.Todo{}, translateError(result.Error)
+	}
 	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+		return entities.Todo{}, r.missingOrModified(primary, ownerID, id)
+	}
+	return r.get(primary, ownerID, id)
+}
+
+// missingOrModified explains why a version checked write matched no row
+func (r *TodoCrudRepositorySql) missingOrModified(primary *gorm.DB, ownerID string, id int) error {
+	if _, err := r.get(primary, ownerID, id); err != nil {
+		return err
 	}
-	return result.Error
+	return todoVersionMismatch(id)
+}
+
+// escapeLike escapes the LIKE wildcards in a user supplied search term. The
+// escape character is given explicitly since SQLite has none by default, and
+// isn't a backslash, which MySQL string literals would treat specially.
+func escapeLike(term string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(term)
 }
@@ -0,0 +1,197 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/database"
+	"tuhuynh.com/go-ioc-gin-example/entities"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+	"tuhuynh.com/go-ioc-gin-example/migrations"
+)
+
+// newTestConfig returns a configuration connected to an in-memory SQLite
+// database with the schema migrated
+func newTestConfig(t *testing.T) *config.Config {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// Every connection to :memory: is a new database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	cfg := &config.Config{DB: db}
+	runner := &migrations.Runner{Log: logger.NewTestLogger(), Config: cfg}
+	runner.PostConstruct()
+	require.NoError(t, runner.Up(context.Background()))
+	return cfg
+}
+
+// newTestRepository returns a repository reading from the replicas of cfg
+func newTestRepository(cfg *config.Config) *TodoCrudRepositorySql {
+	resolver := &database.Resolver{Config: cfg, Log: logger.NewTestLogger()}
+	resolver.PostConstruct()
+	return &TodoCrudRepositorySql{Resolver: resolver}
+}
+
+// createTodos stores todos for the owner, one second apart, and returns them
+// as stored
+func createTodos(t *testing.T, r *TodoCrudRepositorySql, owner string, titles ...string) []entities.Todo {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, title := range titles {
+		at := start.Add(time.Duration(i) * time.Second)
+		_, err := r.Create(ctx, entities.Todo{OwnerID: owner, Title: title, CreatedAt: at, UpdatedAt: at})
+		require.NoError(t, err)
+	}
+
+	page, err := r.List(ctx, TodoListQuery{OwnerID: owner, Limit: MaxListLimit})
+	require.NoError(t, err)
+	return page.Todos
+}
+
+func titles(todos []entities.Todo) []string {
+	titles := make([]string, 0, len(todos))
+	for _, todo := range todos {
+		titles = append(titles, todo.Title)
+	}
+	return titles
+}
+
+func TestTodoCrudRepositorySqlList(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepository(newTestConfig(t))
+	createTodos(t, r, "alice", "Buy milk", "Walk the dog", "100% done", "snake_case", "Pay bills")
+	createTodos(t, r, "bob", "Buy bread")
+
+	page, err := r.List(ctx, TodoListQuery{OwnerID: "alice", Limit: 2, Sort: SortCreatedAtDesc})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Pay bills", "snake_case"}, titles(page.Todos))
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = r.List(ctx, TodoListQuery{OwnerID: "alice", Limit: 2, Sort: SortCreatedAtDesc, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"100% done", "Walk the dog"}, titles(page.Todos))
+
+	page, err = r.List(ctx, TodoListQuery{OwnerID: "alice", Limit: 2, Sort: SortCreatedAtDesc, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Buy milk"}, titles(page.Todos))
+	assert.Empty(t, page.NextCursor)
+
+	// Search ignores case and matches wildcards literally
+	tests := map[string][]string{
+		"buy": {"Buy milk"},
+		"%":   {"100% done"},
+		"_":   {"snake_case"},
+		"!":   {},
+	}
+	for search, want := range tests {
+		page, err = r.List(ctx, TodoListQuery{OwnerID: "alice", Search: search})
+		require.NoError(t, err)
+		assert.Equal(t, want, titles(page.Todos), search)
+	}
+
+	completed := true
+	page, err = r.List(ctx, TodoListQuery{OwnerID: "alice", Completed: &completed})
+	require.NoError(t, err)
+	assert.Empty(t, page.Todos)
+}
+
+func TestTodoCrudRepositorySqlWrite(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepository(newTestConfig(t))
+	todo := createTodos(t, r, "alice", "Buy milk")[0]
+	assert.Equal(t, 1, todo.Version)
+
+	// Other owners can't see or change the todo
+	_, err := r.Get(ctx, "bob", todo.ID)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	assert.ErrorIs(t, r.Delete(ctx, "bob", todo.ID, 0), apperrors.ErrNotFound)
+
+	todo.Title = "Buy oat milk"
+	updated, err := r.Update(ctx, todo)
+	require.NoError(t, err)
+	assert.Equal(t, "Buy oat milk", updated.Title)
+	assert.Equal(t, 2, updated.Version)
+
+	// A stale version is rejected
+	completed := true
+	_, err = r.Patch(ctx, "alice", todo.ID, TodoPatch{Completed: &completed, Version: 1})
+	assert.ErrorIs(t, err, apperrors.ErrPreconditionFailed)
+
+	patched, err := r.Patch(ctx, "alice", todo.ID, TodoPatch{Completed: &completed, Version: 2})
+	require.NoError(t, err)
+	assert.True(t, patched.Completed)
+	assert.Equal(t, "Buy oat milk", patched.Title)
+	assert.Equal(t, 3, patched.Version)
+
+	assert.ErrorIs(t, r.Delete(ctx, "alice", todo.ID, 2), apperrors.ErrPreconditionFailed)
+	require.NoError(t, r.Delete(ctx, "alice", todo.ID, 3))
+	_, err = r.Get(ctx, "alice", todo.ID)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+}
+
+func TestTodoCrudRepositorySqlReplica(t *testing.T) {
+	cfg := newTestConfig(t)
+	// The replica never receives the writes, making reads from it visible
+	cfg.Replicas = []*gorm.DB{newTestConfig(t).DB}
+	cfg.DBReplicaCheckInterval = time.Hour
+	cfg.DBReadYourWritesWindow = time.Minute
+	cfg.HealthCheckTimeout = time.Second
+	r := newTestRepository(cfg)
+	t.Cleanup(r.Resolver.PreDestroy)
+
+	// A request reads its own writes from the primary
+	ctx := database.WithSession(context.Background())
+	created, err := r.Create(ctx, entities.Todo{OwnerID: "alice", Title: "Buy milk"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, created.Version)
+	page, err := r.List(ctx, TodoListQuery{OwnerID: "alice"})
+	require.NoError(t, err)
+	require.Len(t, page.Todos, 1)
+
+	// Updates read the row back from the primary
+	todo := page.Todos[0]
+	todo.Title = "Buy oat milk"
+	updated, err := r.Update(context.Background(), todo)
+	require.NoError(t, err)
+	assert.Equal(t, "Buy oat milk", updated.Title)
+
+	// Other requests read from the replica
+	page, err = r.List(database.WithSession(context.Background()), TodoListQuery{OwnerID: "alice"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Todos)
+	_, err = r.Get(context.Background(), "alice", todo.ID)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+}
+
+func TestTodoCrudRepositorySqlTx(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepository(newTestConfig(t))
+	txManager := &database.TxManagerSql{Resolver: r.Resolver}
+	todo := createTodos(t, r, "alice", "Buy milk")[0]
+
+	// A failing unit of work leaves nothing behind
+	err := txManager.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := r.Create(ctx, entities.Todo{OwnerID: "alice", Title: "Walk the dog"})
+		require.NoError(t, err)
+		todo.Title = "Buy oat milk"
+		_, err = r.Update(ctx, todo)
+		require.NoError(t, err)
+		return r.Delete(ctx, "alice", todo.ID, 1)
+	})
+	assert.ErrorIs(t, err, apperrors.ErrPreconditionFailed)
+
+	page, err := r.List(ctx, TodoListQuery{OwnerID: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Buy milk"}, titles(page.Todos))
+	assert.Equal(t, 1, page.Todos[0].Version)
+}
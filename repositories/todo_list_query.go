@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/entities"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = apperrors.Validation("invalid cursor")
+	ErrInvalidSort   = apperrors.Validation("invalid sort, expected one of created_at, -created_at, updated_at, -updated_at")
+)
+
+// TodoSort names the column a listing is ordered by; a leading "-" means descending
+type TodoSort string
+
+const (
+	SortCreatedAtAsc  TodoSort = "created_at"
+	SortCreatedAtDesc TodoSort = "-created_at"
+	SortUpdatedAtAsc  TodoSort = "updated_at"
+	SortUpdatedAtDesc TodoSort = "-updated_at"
+)
+
+// Column returns the database column and whether the order is descending
+func (s TodoSort) Column() (string, bool) {
+	switch s {
+	case SortCreatedAtDesc:
+		return "created_at", true
+	case SortUpdatedAtAsc:
+		return "updated_at", false
+	case SortUpdatedAtDesc:
+		return "updated_at", true
+	default:
+		return "created_at", false
+	}
+}
+
+// Valid reports whether s is one of the supported sort orders
+func (s TodoSort) Valid() bool {
+	switch s {
+	case SortCreatedAtAsc, SortCreatedAtDesc, SortUpdatedAtAsc, SortUpdatedAtDesc:
+		return true
+	}
+	return false
+}
+
+// TodoListQuery holds the pagination, filter and sort options for listing todos
+type TodoListQuery struct {
+	OwnerID   string
+	Limit     int
+	Cursor    string
+	Completed *bool
+	Search    string
+	Sort      TodoSort
+}
+
+// TodoPage is a single page of todos plus the cursor for the following page
+type TodoPage struct {
+	Todos      []entities.Todo `json:"data"`
+	NextCursor string          `json:"next_cursor"`
+}
+
+// TodoCursor is the decoded form of the opaque cursor handed to clients.
+// It records the sort it was issued for so it can't be replayed against another order.
+type TodoCursor struct {
+	Sort  TodoSort  `json:"s"`
+	Value time.Time `json:"v"`
+	ID    int       `json:"id"`
+}
+
+// Normalize applies defaults, clamps the limit and validates the sort order
+func (q TodoListQuery) Normalize() (TodoListQuery, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultListLimit
+	}
+	if q.Limit > MaxListLimit {
+		q.Limit = MaxListLimit
+	}
+	if q.Sort == "" {
+		q.Sort = SortCreatedAtAsc
+	}
+	if !q.Sort.Valid() {
+		return q, ErrInvalidSort
+	}
+	if _, err := q.DecodeCursor(); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// DecodeCursor parses the opaque cursor, returning nil when the query starts at the first page
+func (q TodoListQuery) DecodeCursor() (*TodoCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor TodoCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != q.Sort {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// EncodeCursor builds the opaque cursor pointing just past todo for the given sort
+func EncodeCursor(sort TodoSort, todo entities.Todo) string {
+	cursor := TodoCursor{Sort: sort, ID: todo.ID}
+	if column, _ := sort.Column(); column == "updated_at" {
+		cursor.Value = todo.UpdatedAt
+	} else {
+		cursor.Value = todo.CreatedAt
+	}
+
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// newTodoPage trims the extra look-ahead row fetched by the repositories and sets the next cursor
+func newTodoPage(todos []entities.Todo, q TodoListQuery) TodoPage {
+	page := TodoPage{Todos: todos}
+	if len(todos) > q.Limit {
+		page.Todos = todos[:q.Limit]
+		page.NextCursor = EncodeCursor(q.Sort, page.Todos[q.Limit-1])
+	}
+	return page
+}
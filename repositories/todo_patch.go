@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"tuhuynh.com/go-ioc-gin-example/entities"
+)
+
+// TodoPatch is a partial update of a todo. A nil field is left untouched.
+type TodoPatch struct {
+	Title       *string
+	Description *string
+	Completed   *bool
+
+	// Version is the version the caller expects to modify, 0 skips the check
+	Version int
+}
+
+// columns returns the column assignments for the fields set in the patch
+func (p TodoPatch) columns() map[string]interface{} {
+	columns := make(map[string]interface{}, 4)
+	if p.Title != nil {
+		columns["title"] = *p.Title
+	}
+	if p.Description != nil {
+		columns["description"] = *p.Description
+	}
+	if p.Completed != nil {
+		columns["completed"] = *p.Completed
+	}
+	return columns
+}
+
+// apply returns todo with the fields set in the patch overwritten
+func (p TodoPatch) apply(todo entities.Todo) entities.Todo {
+	if p.Title != nil {
+		todo.Title = *p.Title
+	}
+	if p.Description != nil {
+		todo.Description = *p.Description
+	}
+	if p.Completed != nil {
+		todo.Completed = *p.Completed
+	}
+	return todo
+}
@@ -0,0 +1,71 @@
+package security
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/entities"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+	"tuhuynh.com/go-ioc-gin-example/repositories"
+)
+
+// mintTestKey stores a new key with the given scopes and returns its secret
+func mintTestKey(t *testing.T, repo repositories.APIKeyRepository, scopes string) (entities.APIKey, string) {
+	secret, prefix, hash, err := GenerateAPIKey()
+	require.NoError(t, err)
+
+	key, err := repo.Create(context.Background(), entities.APIKey{
+		Name: "batch", Prefix: prefix, Hash: hash, Subject: "service:batch", Scopes: scopes,
+	})
+	require.NoError(t, err)
+	return key, secret
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, hash, err := GenerateAPIKey()
+	assert.NoError(t, err)
+	assert.Regexp(t, "^tdk_[A-Za-z0-9_-]{43}$", key)
+	assert.Equal(t, key[:12], prefix)
+	assert.Equal(t, HashAPIKey(key), hash)
+	assert.NotContains(t, hash, key[4:])
+
+	other, _, _, err := GenerateAPIKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	ctx := context.Background()
+	repo := &repositories.APIKeyRepositoryMock{}
+	a := &APIKeyAuthenticator{Repository: repo, Log: logger.NewTestLogger()}
+	a.PostConstruct()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	stored, secret := mintTestKey(t, repo, "todos:read")
+
+	principal, err := a.Authenticate(ctx, secret)
+	assert.NoError(t, err)
+	assert.Equal(t, Principal{Subject: "service:batch", Method: "api_key", Scopes: []string{"todos:read"}}, principal)
+
+	// Use is recorded, but not more than once per resolution
+	stored, _ = repo.Get(ctx, stored.ID)
+	assert.Equal(t, now, *stored.LastUsedAt)
+	now = now.Add(time.Second)
+	_, err = a.Authenticate(ctx, secret)
+	assert.NoError(t, err)
+	stored, _ = repo.Get(ctx, stored.ID)
+	assert.Equal(t, now.Add(-time.Second), *stored.LastUsedAt)
+
+	_, err = a.Authenticate(ctx, "tdk_unknown")
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+
+	assert.NoError(t, repo.Revoke(ctx, stored.ID, now))
+	_, err = a.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+}
@@ -0,0 +1,70 @@
+package security
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+// Authenticator identifies the caller of a request, either a service by its
+// X-API-Key header or a user by the JWT in its Authorization header
+type Authenticator struct {
+	Component struct{}
+	JWT       *JWTAuthenticator    `autowired:"true"`
+	APIKeys   *APIKeyAuthenticator `autowired:"true"`
+	Log       logger.Logger        `autowired:"true"`
+}
+
+// Handle requires valid credentials and stores the caller in the request context
+func (a *Authenticator) Handle(ctx *gin.Context) {
+	var principal Principal
+	var err error
+
+	if key := ctx.GetHeader(APIKeyHeader); key != "" {
+		principal, err = a.APIKeys.Authenticate(ctx.Request.Context(), key)
+	} else if token, ok := bearerToken(ctx); ok {
+		principal, err = a.JWT.Authenticate(token)
+	} else {
+		err = apperrors.Unauthenticated("missing bearer token or API key")
+	}
+
+	if err != nil {
+		if apperrors.CodeOf(err) == apperrors.CodeUnauthenticated {
+			ctx.Header("WWW-Authenticate", `Bearer realm="todos"`)
+		}
+		ctx.Error(err)
+		ctx.Abort()
+		return
+	}
+
+	authenticated(ctx, a.Log, principal)
+	ctx.Next()
+}
+
+// RequirePermission rejects authenticated callers that don't hold permission
+func RequirePermission(permission Permission) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		principal, ok := PrincipalFromContext(ctx.Request.Context())
+		if !ok {
+			ctx.Error(apperrors.Unauthenticated("authentication required"))
+			ctx.Abort()
+			return
+		}
+		if err := Authorize(principal, permission); err != nil {
+			ctx.Error(err)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func bearerToken(ctx *gin.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(ctx.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,94 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+	"tuhuynh.com/go-ioc-gin-example/middleware"
+	"tuhuynh.com/go-ioc-gin-example/repositories"
+)
+
+func TestAuthenticator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &repositories.APIKeyRepositoryMock{}
+	apiKeys := &APIKeyAuthenticator{Repository: repo, Log: logger.NewNopLogger()}
+	apiKeys.PostConstruct()
+	auth := &Authenticator{
+		JWT:     newAuthenticator(&config.Config{JWTSecret: testSecret}),
+		APIKeys: apiKeys,
+		Log:     logger.NewNopLogger(),
+	}
+	_, readKey := mintTestKey(t, repo, "todos:read")
+
+	r := gin.New()
+	r.Use((&middleware.ErrorHandler{Log: logger.NewNopLogger()}).Handle, auth.Handle)
+	r.GET("/todos", RequirePermission(PermissionTodosRead), func(ctx *gin.Context) {
+		principal, _ := PrincipalFromContext(ctx.Request.Context())
+		ctx.String(http.StatusOK, principal.Subject+" "+logger.UserIDFromContext(ctx.Request.Context()))
+	})
+	r.POST("/todos", RequirePermission(PermissionTodosWrite), func(ctx *gin.Context) {
+		ctx.Status(http.StatusCreated)
+	})
+
+	serve := func(method string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/todos", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("bearer token", func(t *testing.T) {
+		token := sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims(), "")
+		w := serve(http.MethodGet, map[string]string{"Authorization": "Bearer " + token})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "alice alice", w.Body.String())
+	})
+
+	t.Run("api key", func(t *testing.T) {
+		w := serve(http.MethodGet, map[string]string{APIKeyHeader: readKey})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "service:batch service:batch", w.Body.String())
+	})
+
+	t.Run("viewer token", func(t *testing.T) {
+		claims := validClaims()
+		claims["roles"] = []string{"viewer"}
+		token := sign(t, jwt.SigningMethodHS256, []byte(testSecret), claims, "")
+
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, map[string]string{"Authorization": "Bearer " + token}).Code)
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, map[string]string{"Authorization": "Bearer " + token}).Code)
+	})
+
+	t.Run("api key without the scope", func(t *testing.T) {
+		w := serve(http.MethodPost, map[string]string{APIKeyHeader: readKey})
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, middleware.ProblemContentType, w.Header().Get("Content-Type"))
+	})
+
+	for name, headers := range map[string]map[string]string{
+		"missing":         {},
+		"wrong scheme":    {"Authorization": "Basic YWxpY2U6c2VjcmV0"},
+		"invalid token":   {"Authorization": "Bearer garbage"},
+		"invalid api key": {APIKeyHeader: "tdk_garbage"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := serve(http.MethodGet, headers)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Equal(t, middleware.ProblemContentType, w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+		})
+	}
+}
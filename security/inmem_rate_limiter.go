@@ -0,0 +1,263 @@
+package security
+
+import (
+	"container/list"
+	"context"
+	"expvar"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"tuhuynh.com/go-ioc-gin-example/config"
+)
+
+const (
+	defaultMaxKeys         = 100000
+	defaultJanitorInterval = time.Minute
+
+	// expvarName is the name the limiter's stats are published under
+	expvarName = "rate_limiter"
+)
+
+// InMemoryRateLimiter keeps buckets in process memory, so every replica
+// enforces the limits on its own. A janitor evicts buckets once they have been
+// idle long enough to be full again, and the least recently used bucket is
+// evicted when Config.RateLimitMaxKeys are tracked.
+type InMemoryRateLimiter struct {
+	Component  struct{}
+	Implements struct{}       `implements:"RateLimiter"`
+	Qualifier  struct{}       `value:"inmem"`
+	Config     *config.Config `autowired:"true"`
+
+	maxKeys    int
+	limits     map[string]*list.Element
+	ll         *list.List // most recently used first
+	mu         sync.Mutex
+	now        func() time.Time
+	rejections atomic.Uint64
+	evictions  atomic.Uint64
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// RateLimiterStats is a snapshot of the limiter's metrics
+type RateLimiterStats struct {
+	TrackedKeys int    `json:"tracked_keys"`
+	Rejections  uint64 `json:"rejections"`
+	Evictions   uint64 `json:"evictions"`
+}
+
+// userRateLimit holds the state of both algorithms; only the fields of the
+// policy's algorithm are used
+type userRateLimit struct {
+	key    string
+	window time.Duration
+
+	// token bucket
+	tokens     float64
+	lastRefill time.Time
+
+	// sliding window
+	windowStart time.Time
+	current     int
+	previous    int
+
+	lastAccess time.Time
+}
+
+// idle reports whether the bucket is untouched for long enough that dropping
+// it can't change a decision: both algorithms have recovered after two windows
+func (ul *userRateLimit) idle(now time.Time) bool {
+	return now.Sub(ul.lastAccess) >= 2*ul.window
+}
+
+// PostConstruct starts the janitor, which runs until PreDestroy
+func (rl *InMemoryRateLimiter) PostConstruct() {
+	rl.maxKeys = defaultMaxKeys
+	interval := defaultJanitorInterval
+	if rl.Config != nil {
+		if rl.Config.RateLimitMaxKeys > 0 {
+			rl.maxKeys = rl.Config.RateLimitMaxKeys
+		}
+		if rl.Config.RateLimitJanitorInterval > 0 {
+			interval = rl.Config.RateLimitJanitorInterval
+		}
+	}
+
+	rl.limits = make(map[string]*list.Element)
+	rl.ll = list.New()
+	if rl.now == nil {
+		rl.now = time.Now
+	}
+
+	// Served by StatsHandler; only the first limiter is published
+	if expvar.Get(expvarName) == nil {
+		expvar.Publish(expvarName, expvar.Func(func() any { return rl.Stats() }))
+	}
+
+	rl.stop = make(chan struct{})
+	rl.done = make(chan struct{})
+	go rl.janitor(interval)
+}
+
+// StatsHandler serves the published limiter stats in the format of expvar's
+// handler. Unlike it, the process's command line and memory stats aren't
+// exposed.
+func StatsHandler(ctx *gin.Context) {
+	body := "{}"
+	if v := expvar.Get(expvarName); v != nil {
+		body = fmt.Sprintf("{%q: %s}", expvarName, v.String())
+	}
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", []byte(body+"\n"))
+}
+
+// PreDestroy stops the janitor
+func (rl *InMemoryRateLimiter) PreDestroy() {
+	close(rl.stop)
+	<-rl.done
+}
+
+func (rl *InMemoryRateLimiter) janitor(interval time.Duration) {
+	defer close(rl.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			rl.evictIdle()
+		case <-rl.stop:
+			return
+		}
+	}
+}
+
+// evictIdle drops every idle bucket
+func (rl *InMemoryRateLimiter) evictIdle() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	for elem := rl.ll.Back(); elem != nil; {
+		prev := elem.Prev()
+		if elem.Value.(*userRateLimit).idle(now) {
+			rl.remove(elem)
+		}
+		elem = prev
+	}
+}
+
+// remove drops a bucket; callers must hold mu
+func (rl *InMemoryRateLimiter) remove(elem *list.Element) {
+	rl.ll.Remove(elem)
+	delete(rl.limits, elem.Value.(*userRateLimit).key)
+	rl.evictions.Add(1)
+}
+
+// Stats returns the number of tracked keys and the rejection and eviction counts
+func (rl *InMemoryRateLimiter) Stats() RateLimiterStats {
+	rl.mu.Lock()
+	trackedKeys := rl.ll.Len()
+	rl.mu.Unlock()
+
+	return RateLimiterStats{
+		TrackedKeys: trackedKeys,
+		Rejections:  rl.rejections.Load(),
+		Evictions:   rl.evictions.Load(),
+	}
+}
+
+// Allow counts one request by key against the policy
+func (rl *InMemoryRateLimiter) Allow(ctx context.Context, policy Policy, key string) Decision {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	bucketKey := policy.Name + "|" + key
+	var ul *userRateLimit
+	if elem, exists := rl.limits[bucketKey]; exists {
+		rl.ll.MoveToFront(elem)
+		ul = elem.Value.(*userRateLimit)
+	} else {
+		// Make room by evicting the least recently used bucket
+		for rl.ll.Len() >= rl.maxKeys {
+			rl.remove(rl.ll.Back())
+		}
+		ul = &userRateLimit{key: bucketKey, tokens: float64(policy.Limit), lastRefill: now, windowStart: now}
+		rl.limits[bucketKey] = rl.ll.PushFront(ul)
+	}
+	ul.lastAccess = now
+	ul.window = policy.Window
+
+	var d Decision
+	if policy.Algorithm == SlidingWindow {
+		d = ul.allowSlidingWindow(policy, now)
+	} else {
+		d = ul.allowTokenBucket(policy, now)
+	}
+	if !d.Allowed {
+		rl.rejections.Add(1)
+	}
+	return d
+}
+
+func (ul *userRateLimit) allowTokenBucket(policy Policy, now time.Time) Decision {
+	limit := float64(policy.Limit)
+	perToken := policy.Window / time.Duration(policy.Limit)
+
+	// Refill tokens based on time passed
+	ul.tokens = math.Min(limit, ul.tokens+float64(now.Sub(ul.lastRefill))/float64(perToken))
+	ul.lastRefill = now
+
+	d := Decision{Limit: policy.Limit}
+	if ul.tokens >= 1 {
+		ul.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = time.Duration((1 - ul.tokens) * float64(perToken))
+	}
+	d.Remaining = int(ul.tokens)
+	d.Reset = time.Duration((limit - ul.tokens) * float64(perToken))
+	return d
+}
+
+func (ul *userRateLimit) allowSlidingWindow(policy Policy, now time.Time) Decision {
+	window := policy.Window
+
+	// Roll the window forward, forgetting everything older than the previous one
+	if elapsed := now.Sub(ul.windowStart); elapsed >= window {
+		windows := elapsed / window
+		if windows == 1 {
+			ul.previous = ul.current
+		} else {
+			ul.previous = 0
+		}
+		ul.current = 0
+		ul.windowStart = ul.windowStart.Add(windows * window)
+	}
+
+	elapsed := now.Sub(ul.windowStart)
+	weight := float64(window-elapsed) / float64(window)
+	estimate := float64(ul.previous)*weight + float64(ul.current)
+
+	d := Decision{Limit: policy.Limit, Reset: window - elapsed}
+	if estimate+1 <= float64(policy.Limit) {
+		ul.current++
+		estimate++
+		d.Allowed = true
+	} else if ul.current+1 > policy.Limit || ul.previous == 0 {
+		// Only the next window can make room
+		d.RetryAfter = window - elapsed
+	} else {
+		// Wait until enough of the previous window has slid out
+		excess := estimate + 1 - float64(policy.Limit)
+		d.RetryAfter = time.Duration(excess / float64(ul.previous) * float64(window))
+	}
+	d.Remaining = max(0, policy.Limit-int(math.Ceil(estimate)))
+	return d
+}
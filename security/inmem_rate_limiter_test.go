@@ -0,0 +1,162 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tuhuynh.com/go-ioc-gin-example/config"
+)
+
+// newTestLimiter returns a limiter whose clock only moves when advance is called
+func newTestLimiter(t *testing.T, cfg *config.Config) (*InMemoryRateLimiter, func(time.Duration)) {
+	var mu sync.Mutex
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rl := &InMemoryRateLimiter{Config: cfg}
+	rl.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	rl.PostConstruct()
+	t.Cleanup(rl.PreDestroy)
+
+	return rl, func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	ctx := context.Background()
+	rl, advance := newTestLimiter(t, nil)
+	policy := Policy{Name: "write", Limit: 3, Window: 3 * time.Second, Algorithm: TokenBucket}
+
+	for i := 2; i >= 0; i-- {
+		d := rl.Allow(ctx, policy, "client")
+		assert.True(t, d.Allowed)
+		assert.Equal(t, i, d.Remaining)
+	}
+
+	d := rl.Allow(ctx, policy, "client")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, time.Second, d.RetryAfter)
+	assert.Equal(t, 3*time.Second, d.Reset)
+
+	// Tokens refill continuously instead of all at once
+	advance(time.Second)
+	assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+	assert.False(t, rl.Allow(ctx, policy, "client").Allowed)
+
+	// Keys and policies are counted separately
+	assert.True(t, rl.Allow(ctx, policy, "other").Allowed)
+	assert.True(t, rl.Allow(ctx, Policy{Name: "read", Limit: 3, Window: time.Minute}, "client").Allowed)
+
+	// The bucket never holds more than the limit
+	advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+	}
+	assert.False(t, rl.Allow(ctx, policy, "client").Allowed)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	rl, advance := newTestLimiter(t, nil)
+	policy := Policy{Name: "write", Limit: 4, Window: 10 * time.Second, Algorithm: SlidingWindow}
+
+	for i := 0; i < 4; i++ {
+		assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+	}
+	d := rl.Allow(ctx, policy, "client")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 0, d.Remaining)
+	assert.Equal(t, 10*time.Second, d.RetryAfter)
+
+	// Halfway into the next window half of the previous one still counts
+	advance(15 * time.Second)
+	assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+	assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+	d = rl.Allow(ctx, policy, "client")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 2500*time.Millisecond, d.RetryAfter)
+
+	advance(d.RetryAfter)
+	assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+
+	// Windows older than the previous one are forgotten
+	advance(time.Minute)
+	for i := 0; i < 4; i++ {
+		assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+	policy := Policy{Name: "write", Limit: 1, Window: time.Minute}
+
+	t.Run("idle buckets", func(t *testing.T) {
+		rl, advance := newTestLimiter(t, nil)
+
+		rl.Allow(ctx, policy, "idle")
+		advance(time.Minute)
+		assert.True(t, rl.Allow(ctx, policy, "active").Allowed)
+		assert.False(t, rl.Allow(ctx, policy, "active").Allowed)
+
+		// Only buckets idle for two windows are full again and safe to drop
+		advance(time.Minute)
+		rl.evictIdle()
+		assert.Equal(t, RateLimiterStats{TrackedKeys: 1, Rejections: 1, Evictions: 1}, rl.Stats())
+		assert.Contains(t, rl.limits, "write|active")
+	})
+
+	t.Run("least recently used over the cap", func(t *testing.T) {
+		rl, advance := newTestLimiter(t, &config.Config{RateLimitMaxKeys: 2})
+
+		rl.Allow(ctx, policy, "a")
+		advance(time.Second)
+		rl.Allow(ctx, policy, "b")
+		advance(time.Second)
+		rl.Allow(ctx, policy, "a")
+		rl.Allow(ctx, policy, "c")
+
+		stats := rl.Stats()
+		assert.Equal(t, 2, stats.TrackedKeys)
+		assert.Equal(t, uint64(1), stats.Evictions)
+		assert.Contains(t, rl.limits, "write|a")
+		assert.NotContains(t, rl.limits, "write|b")
+	})
+
+	t.Run("janitor", func(t *testing.T) {
+		rl, advance := newTestLimiter(t, &config.Config{RateLimitJanitorInterval: time.Millisecond})
+
+		rl.Allow(ctx, policy, "idle")
+		advance(2 * time.Minute)
+		assert.Eventually(t, func() bool {
+			return rl.Stats().TrackedKeys == 0
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func TestStatsHandler(t *testing.T) {
+	newTestLimiter(t, nil)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	StatsHandler(ctx)
+
+	// Only the limiter is served, not cmdline or memstats
+	var vars map[string]RateLimiterStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &vars))
+	assert.Len(t, vars, 1)
+	assert.Contains(t, vars, expvarName)
+}
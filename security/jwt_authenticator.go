@@ -0,0 +1,128 @@
+package security
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+// clockSkew is how far token timestamps may be off from our clock
+const clockSkew = 30 * time.Second
+
+var errInvalidToken = apperrors.Unauthenticated("invalid or expired token")
+
+// JWTAuthenticator validates bearer tokens signed with HS256 using
+// Config.JWTSecret or with RS256 using a key of the JWKS file at
+// Config.JWTJWKSFile. When neither is configured every token is rejected.
+type JWTAuthenticator struct {
+	Component struct{}
+	Config    *config.Config `autowired:"true"`
+	Log       logger.Logger  `autowired:"true"`
+
+	secret  []byte
+	rsaKeys map[string]*rsa.PublicKey
+	parser  *jwt.Parser
+}
+
+func (a *JWTAuthenticator) PostConstruct() {
+	var methods []string
+	if a.Config.JWTSecret != "" {
+		a.secret = []byte(a.Config.JWTSecret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if a.Config.JWTJWKSFile != "" {
+		keys, err := loadJWKS(a.Config.JWTJWKSFile)
+		if err != nil {
+			a.Log.Fatalf("failed to load JWKS from %s: %v", a.Config.JWTJWKSFile, err)
+		}
+		a.rsaKeys = keys
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		a.Log.Warn("Neither JWT_SECRET nor JWT_JWKS_FILE is set, every bearer token will be rejected")
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithExpirationRequired(),
+	}
+	if a.Config.JWTIssuer != "" {
+		options = append(options, jwt.WithIssuer(a.Config.JWTIssuer))
+	}
+	if a.Config.JWTAudience != "" {
+		options = append(options, jwt.WithAudience(a.Config.JWTAudience))
+	}
+	a.parser = jwt.NewParser(options...)
+}
+
+// jwtClaims are the registered claims plus the OAuth 2.0 scope claim and
+// the caller's roles
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Scope string   `json:"scope"`
+	Roles []string `json:"roles"`
+}
+
+// Authenticate validates a token and returns the caller it was issued to.
+// Tokens without a roles claim are editors, so users may manage their own
+// todos; unknown roles and scopes are ignored.
+func (a *JWTAuthenticator) Authenticate(tokenString string) (Principal, error) {
+	var claims jwtClaims
+	if _, err := a.parser.ParseWithClaims(tokenString, &claims, a.key); err != nil {
+		return Principal{}, apperrors.Wrap(apperrors.CodeUnauthenticated, err, errInvalidToken.Message)
+	}
+	if claims.Subject == "" {
+		return Principal{}, apperrors.Unauthenticated("token has no subject")
+	}
+
+	roles := []Role{RoleEditor}
+	if claims.Roles != nil {
+		roles = []Role{}
+		for _, role := range claims.Roles {
+			if ValidRole(role) && !slices.Contains(roles, Role(role)) {
+				roles = append(roles, Role(role))
+			}
+		}
+	}
+
+	var scopes []string
+	for _, scope := range strings.Fields(claims.Scope) {
+		if ValidPermission(scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return Principal{Subject: claims.Subject, Method: "jwt", Roles: roles, Scopes: scopes}, nil
+}
+
+// key picks the verification key for the token's algorithm. Keys are never
+// shared between algorithms, so an RSA public key can't be used as an HMAC secret.
+func (a *JWTAuthenticator) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		if len(a.secret) == 0 {
+			return nil, errors.New("no shared secret configured")
+		}
+		return a.secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" && len(a.rsaKeys) == 1 {
+			for _, key := range a.rsaKeys {
+				return key, nil
+			}
+		}
+		if key, ok := a.rsaKeys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return nil, errors.New("unsupported signing method")
+}
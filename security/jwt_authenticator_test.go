@@ -0,0 +1,152 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+const testSecret = "test-secret"
+
+func newAuthenticator(cfg *config.Config) *JWTAuthenticator {
+	a := &JWTAuthenticator{Config: cfg, Log: logger.NewTestLogger()}
+	a.PostConstruct()
+	return a
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims, kid string) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+// writeJWKS stores the public key in a JWKS file and returns its path
+func writeJWKS(t *testing.T, key *rsa.PublicKey, kid string) string {
+	set := map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestJWTAuthenticatorHS256(t *testing.T) {
+	a := newAuthenticator(&config.Config{JWTSecret: testSecret, JWTIssuer: "issuer"})
+	secret := []byte(testSecret)
+
+	claims := validClaims()
+	claims["iss"] = "issuer"
+	principal, err := a.Authenticate(sign(t, jwt.SigningMethodHS256, secret, claims, ""))
+	assert.NoError(t, err)
+	assert.Equal(t, Principal{Subject: "alice", Method: "jwt", Roles: []Role{RoleEditor}}, principal)
+
+	// Roles and scopes come from their claims, unknown ones are ignored
+	claims["roles"] = []string{"viewer", "owner"}
+	claims["scope"] = "admin billing:read"
+	principal, err = a.Authenticate(sign(t, jwt.SigningMethodHS256, secret, claims, ""))
+	assert.NoError(t, err)
+	assert.Equal(t, []Role{RoleViewer}, principal.Roles)
+	assert.Equal(t, []string{"admin"}, principal.Scopes)
+
+	// An empty roles claim grants nothing
+	claims["roles"] = []string{}
+	delete(claims, "scope")
+	principal, err = a.Authenticate(sign(t, jwt.SigningMethodHS256, secret, claims, ""))
+	assert.NoError(t, err)
+	assert.Empty(t, principal.Roles)
+	delete(claims, "roles")
+
+	tests := map[string]string{
+		"wrong secret":   sign(t, jwt.SigningMethodHS256, []byte("other"), claims, ""),
+		"wrong issuer":   sign(t, jwt.SigningMethodHS256, secret, validClaims(), ""),
+		"expired":        sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "alice", "iss": "issuer", "exp": time.Now().Add(-time.Hour).Unix()}, ""),
+		"no expiry":      sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "alice", "iss": "issuer"}, ""),
+		"no subject":     sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"iss": "issuer", "exp": time.Now().Add(time.Hour).Unix()}, ""),
+		"unsigned":       sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claims, ""),
+		"not a token":    "garbage",
+		"wrong method":   sign(t, jwt.SigningMethodHS512, secret, claims, ""),
+		"truncated":      sign(t, jwt.SigningMethodHS256, secret, claims, "")[:20],
+		"empty subject":  sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "", "iss": "issuer", "exp": time.Now().Add(time.Hour).Unix()}, ""),
+		"tampered claim": tamper(sign(t, jwt.SigningMethodHS256, secret, claims, "")),
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := a.Authenticate(token)
+			assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+		})
+	}
+}
+
+// tamper swaps the payload of a token for one claiming another subject
+func tamper(token string) string {
+	parts := strings.Split(token, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory","iss":"issuer","exp":9999999999}`))
+	return strings.Join(parts, ".")
+}
+
+func TestJWTAuthenticatorRS256(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	a := newAuthenticator(&config.Config{JWTJWKSFile: writeJWKS(t, &privateKey.PublicKey, "key-1")})
+
+	principal, err := a.Authenticate(sign(t, jwt.SigningMethodRS256, privateKey, validClaims(), "key-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", principal.Subject)
+
+	// A single key is also used for tokens without a key id
+	_, err = a.Authenticate(sign(t, jwt.SigningMethodRS256, privateKey, validClaims(), ""))
+	assert.NoError(t, err)
+
+	_, err = a.Authenticate(sign(t, jwt.SigningMethodRS256, privateKey, validClaims(), "key-2"))
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = a.Authenticate(sign(t, jwt.SigningMethodRS256, otherKey, validClaims(), "key-1"))
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+
+	// The public key must not be accepted as an HMAC secret
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&privateKey.PublicKey)})
+	_, err = a.Authenticate(sign(t, jwt.SigningMethodHS256, publicPEM, validClaims(), "key-1"))
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+}
+
+func TestJWTAuthenticatorNotConfigured(t *testing.T) {
+	a := newAuthenticator(&config.Config{})
+
+	// An empty secret must not verify tokens signed with an empty key
+	_, err := a.Authenticate(sign(t, jwt.SigningMethodHS256, []byte{}, validClaims(), ""))
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+}
@@ -0,0 +1,45 @@
+package security
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// Subject identifies the caller and owns the todos it creates
+	Subject string
+	// Method is how the caller authenticated, "jwt" or "api_key"
+	Method string
+	// Roles are the roles the caller was assigned
+	Roles []Role
+	// Scopes are the permissions the caller was granted directly
+	Scopes []string
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated caller
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated caller stored in ctx, if any
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// authenticated stores the principal in the request context and adds its
+// subject to the request scoped logger
+func authenticated(ctx *gin.Context, log logger.Logger, principal Principal) {
+	reqCtx := ctx.Request.Context()
+	scoped := log.FromContext(reqCtx).With(logger.UserIDField, principal.Subject)
+
+	reqCtx = WithPrincipal(reqCtx, principal)
+	reqCtx = logger.WithUserID(reqCtx, principal.Subject)
+	reqCtx = logger.NewContext(reqCtx, scoped)
+	ctx.Request = ctx.Request.WithContext(reqCtx)
+}
@@ -0,0 +1,152 @@
+package security
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+// RateLimiter counts requests against a policy. Implementations are selected
+// by qualifier: "inmem" limits per replica, "redis" limits across replicas.
+type RateLimiter interface {
+	Allow(ctx context.Context, policy Policy, key string) Decision
+}
+
+// Algorithm selects how a bucket refills
+type Algorithm string
+
+const (
+	// TokenBucket refills continuously at Limit tokens per Window and allows bursts up to Limit
+	TokenBucket Algorithm = "token_bucket"
+	// SlidingWindow allows Limit requests in any Window, weighting the previous window by overlap
+	SlidingWindow Algorithm = "sliding_window"
+)
+
+// KeyFunc identifies the caller a request is counted against
+type KeyFunc func(ctx *gin.Context) string
+
+// KeyByIP counts requests per client IP
+func KeyByIP(ctx *gin.Context) string {
+	return "ip:" + ctx.ClientIP()
+}
+
+// KeyByAPIKey counts requests per X-API-Key, falling back to the client IP.
+// The key is hashed so secrets are never held in the limiter's memory.
+func KeyByAPIKey(ctx *gin.Context) string {
+	apiKey := ctx.GetHeader(APIKeyHeader)
+	if apiKey == "" {
+		return KeyByIP(ctx)
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return "key:" + hex.EncodeToString(sum[:8])
+}
+
+// KeyByUser counts requests per authenticated user, falling back to the client IP
+func KeyByUser(ctx *gin.Context) string {
+	userID := logger.UserIDFromContext(ctx.Request.Context())
+	if userID == "" {
+		return KeyByIP(ctx)
+	}
+	return "user:" + userID
+}
+
+var keyFuncs = map[string]KeyFunc{
+	"ip":      KeyByIP,
+	"api_key": KeyByAPIKey,
+	"user":    KeyByUser,
+}
+
+// Policy is a named limit applied to a group of routes
+type Policy struct {
+	Name      string
+	Limit     int
+	Window    time.Duration
+	Algorithm Algorithm
+	Key       KeyFunc
+}
+
+// Decision is the outcome of counting one request against a policy
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again or the window ends
+	RetryAfter time.Duration // until the next request would be allowed, when denied
+}
+
+const (
+	defaultLimit  = 50
+	defaultWindow = time.Minute
+)
+
+// NewPolicy returns the policy configured under name, e.g. "read" or "write"
+func NewPolicy(cfg *config.Config, name string) Policy {
+	policy := Policy{
+		Name:      name,
+		Limit:     defaultLimit,
+		Window:    defaultWindow,
+		Algorithm: TokenBucket,
+		Key:       KeyByIP,
+	}
+
+	if cfg == nil {
+		return policy
+	}
+	limits, ok := cfg.RateLimits[name]
+	if !ok {
+		return policy
+	}
+
+	if limits.Limit > 0 {
+		policy.Limit = limits.Limit
+	}
+	if limits.Window > 0 {
+		policy.Window = limits.Window
+	}
+	if limits.Algorithm == string(SlidingWindow) {
+		policy.Algorithm = SlidingWindow
+	}
+	if key, ok := keyFuncs[limits.KeyBy]; ok {
+		policy.Key = key
+	}
+	return policy
+}
+
+// Middleware enforces the policy, setting the X-RateLimit-* headers on every
+// response and Retry-After on 429s. Reset and Retry-After are in seconds.
+func Middleware(limiter RateLimiter, policy Policy) gin.HandlerFunc {
+	keyFunc := policy.Key
+	if keyFunc == nil {
+		keyFunc = KeyByIP
+	}
+
+	return func(ctx *gin.Context) {
+		d := limiter.Allow(ctx.Request.Context(), policy, keyFunc(ctx))
+
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		ctx.Header("X-RateLimit-Reset", strconv.Itoa(seconds(d.Reset)))
+
+		if !d.Allowed {
+			ctx.Header("Retry-After", strconv.Itoa(seconds(d.RetryAfter)))
+			ctx.Error(apperrors.RateLimited("Rate limit exceeded. Try again later."))
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// seconds rounds a duration up to whole seconds, as the headers require
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
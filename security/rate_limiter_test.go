@@ -0,0 +1,77 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+	"tuhuynh.com/go-ioc-gin-example/middleware"
+)
+
+func TestPolicy(t *testing.T) {
+	cfg := &config.Config{RateLimits: map[string]config.RateLimitPolicy{
+		"read": {Limit: 300, Window: time.Minute, Algorithm: "sliding_window", KeyBy: "user"},
+	}}
+
+	read := NewPolicy(cfg, "read")
+	assert.Equal(t, 300, read.Limit)
+	assert.Equal(t, time.Minute, read.Window)
+	assert.Equal(t, SlidingWindow, read.Algorithm)
+
+	write := NewPolicy(cfg, "write")
+	assert.Equal(t, defaultLimit, write.Limit)
+	assert.Equal(t, TokenBucket, write.Algorithm)
+}
+
+func TestKeyFuncs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request, _ = http.NewRequest(http.MethodGet, "/", nil)
+	ctx.Request.RemoteAddr = "10.0.0.1:1234"
+
+	assert.Equal(t, "ip:10.0.0.1", KeyByIP(ctx))
+	assert.Equal(t, "ip:10.0.0.1", KeyByAPIKey(ctx))
+	assert.Equal(t, "ip:10.0.0.1", KeyByUser(ctx))
+
+	ctx.Request.Header.Set("X-API-Key", "secret")
+	assert.Regexp(t, "^key:[0-9a-f]{16}$", KeyByAPIKey(ctx))
+	assert.NotContains(t, KeyByAPIKey(ctx), "secret")
+
+	ctx.Request = ctx.Request.WithContext(logger.WithUserID(ctx.Request.Context(), "42"))
+	assert.Equal(t, "user:42", KeyByUser(ctx))
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rl, _ := newTestLimiter(t, nil)
+
+	r := gin.New()
+	r.Use((&middleware.ErrorHandler{Log: logger.NewNopLogger()}).Handle)
+	policy := Policy{Name: "write", Limit: 1, Window: time.Minute, Key: KeyByIP}
+	r.POST("/todos", Middleware(rl, policy), func(ctx *gin.Context) {
+		ctx.Status(http.StatusCreated)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/todos", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("X-RateLimit-Reset"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, middleware.ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+}
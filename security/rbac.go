@@ -0,0 +1,68 @@
+package security
+
+import (
+	"slices"
+
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+)
+
+// Permission is an operation a route requires. API keys and the JWT scope
+// claim grant permissions directly; roles grant fixed sets of them.
+type Permission string
+
+const (
+	PermissionTodosRead  Permission = "todos:read"
+	PermissionTodosWrite Permission = "todos:write"
+	PermissionAdmin      Permission = "admin"
+)
+
+// ValidPermission reports whether permission is one of the known permissions
+func ValidPermission(permission string) bool {
+	switch Permission(permission) {
+	case PermissionTodosRead, PermissionTodosWrite, PermissionAdmin:
+		return true
+	}
+	return false
+}
+
+// Role is a named set of permissions assigned to users
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleViewer: {PermissionTodosRead},
+	RoleEditor: {PermissionTodosRead, PermissionTodosWrite},
+	RoleAdmin:  {PermissionTodosRead, PermissionTodosWrite, PermissionAdmin},
+}
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[Role(role)]
+	return ok
+}
+
+// Can reports whether the caller holds permission through a role or a scope
+func (p Principal) Can(permission Permission) bool {
+	if slices.Contains(p.Scopes, string(permission)) {
+		return true
+	}
+	for _, role := range p.Roles {
+		if slices.Contains(rolePermissions[role], permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize returns a forbidden error unless the caller holds permission
+func Authorize(principal Principal, permission Permission) error {
+	if !principal.Can(permission) {
+		return apperrors.Forbidden("the %s permission is required", permission)
+	}
+	return nil
+}
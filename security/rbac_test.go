@@ -0,0 +1,44 @@
+package security
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+)
+
+func TestAuthorize(t *testing.T) {
+	tests := map[string]struct {
+		principal Principal
+		allowed   []Permission
+	}{
+		"viewer":          {Principal{Roles: []Role{RoleViewer}}, []Permission{PermissionTodosRead}},
+		"editor":          {Principal{Roles: []Role{RoleEditor}}, []Permission{PermissionTodosRead, PermissionTodosWrite}},
+		"admin":           {Principal{Roles: []Role{RoleAdmin}}, []Permission{PermissionTodosRead, PermissionTodosWrite, PermissionAdmin}},
+		"unknown role":    {Principal{Roles: []Role{"owner"}}, nil},
+		"nothing granted": {Principal{}, nil},
+		"scopes only":     {Principal{Scopes: []string{"todos:write"}}, []Permission{PermissionTodosWrite}},
+		"role and scope":  {Principal{Roles: []Role{RoleViewer}, Scopes: []string{"admin"}}, []Permission{PermissionTodosRead, PermissionAdmin}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, permission := range []Permission{PermissionTodosRead, PermissionTodosWrite, PermissionAdmin} {
+				err := Authorize(tt.principal, permission)
+				if slices.Contains(tt.allowed, permission) {
+					assert.NoError(t, err, permission)
+				} else {
+					assert.ErrorIs(t, err, apperrors.ErrForbidden, permission)
+				}
+			}
+		})
+	}
+}
+
+func TestValidRoleAndPermission(t *testing.T) {
+	assert.True(t, ValidRole("viewer"))
+	assert.False(t, ValidRole("owner"))
+	assert.True(t, ValidPermission("todos:write"))
+	assert.False(t, ValidPermission("viewer"))
+}
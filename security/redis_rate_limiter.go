@@ -0,0 +1,159 @@
+package security
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"tuhuynh.com/go-ioc-gin-example/circuit"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+// redisKeyPrefix namespaces the rate limit keys in Redis
+const redisKeyPrefix = "ratelimit:"
+
+// gcraScript implements the generic cell rate algorithm. It stores a single
+// theoretical arrival time per key and reads the clock from Redis, so every
+// replica agrees on the state. Times are in microseconds.
+//
+// KEYS[1] bucket key, ARGV[1] limit, ARGV[2] emission interval
+// Returns {allowed, remaining, reset, retry_after}
+var gcraScript = redis.NewScript(`
+redis.replicate_commands()
+
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local tat = tonumber(redis.call("GET", key))
+if not tat or tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval
+local allow_at = new_tat - interval * limit
+local diff = now - allow_at
+
+if diff < 0 then
+	return {0, 0, tat - now, -diff}
+end
+
+redis.call("SET", key, new_tat, "PX", math.ceil((new_tat - now) / 1000))
+return {1, math.floor(diff / interval), new_tat - now, 0}
+`)
+
+// slidingWindowScript counts requests in fixed windows and weights the
+// previous window by how much of it the sliding window still covers, like
+// InMemoryRateLimiter does. Times are in microseconds.
+//
+// KEYS[1] window key, ARGV[1] limit, ARGV[2] window
+// Returns {allowed, remaining, reset, retry_after}
+var slidingWindowScript = redis.NewScript(`
+redis.replicate_commands()
+
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local state = redis.call("HMGET", key, "start", "current", "previous")
+local start = tonumber(state[1]) or now
+local current = tonumber(state[2]) or 0
+local previous = tonumber(state[3]) or 0
+
+-- Roll the window forward, forgetting everything older than the previous one
+local elapsed = now - start
+if elapsed >= window then
+	local windows = math.floor(elapsed / window)
+	if windows == 1 then
+		previous = current
+	else
+		previous = 0
+	end
+	current = 0
+	start = start + windows * window
+	elapsed = now - start
+end
+
+local reset = window - elapsed
+local estimate = previous * reset / window + current
+
+if estimate + 1 <= limit then
+	current = current + 1
+	-- Formatted, as the default conversion would round the start
+	redis.call("HSET", key, "start", string.format("%.0f", start), "current", current, "previous", previous)
+	-- The counts matter until the next window has ended too
+	redis.call("PEXPIRE", key, math.ceil((reset + window) / 1000))
+	return {1, math.max(0, limit - math.ceil(estimate + 1)), reset, 0}
+end
+
+local retry_after = reset
+if current + 1 <= limit and previous > 0 then
+	-- Wait until enough of the previous window has slid out
+	retry_after = math.ceil((estimate + 1 - limit) / previous * window)
+end
+return {0, math.max(0, limit - math.ceil(estimate)), reset, retry_after}
+`)
+
+// RedisRateLimiter shares buckets between replicas through Redis. Token
+// buckets are enforced with GCRA, which behaves like one, and sliding windows
+// with counts of the current and previous window. After
+// Redis fails it is skipped for Config.RedisRetryInterval, and requests are
+// counted by Fallback instead.
+type RedisRateLimiter struct {
+	Component  struct{}
+	Implements struct{}       `implements:"RateLimiter"`
+	Qualifier  struct{}       `value:"redis"`
+	Config     *config.Config `autowired:"true"`
+	Log        logger.Logger  `autowired:"true"`
+	Fallback   RateLimiter    `autowired:"true" qualifier:"inmem"`
+
+	breaker  circuit.Breaker
+	degraded atomic.Bool
+}
+
+// Allow counts one request by key against the policy
+func (rl *RedisRateLimiter) Allow(ctx context.Context, policy Policy, key string) Decision {
+	client := rl.Config.Redis
+	if client == nil || !rl.breaker.Allow(time.Now()) {
+		return rl.Fallback.Allow(ctx, policy, key)
+	}
+
+	// Sliding windows keep a hash rather than a number, so they get keys of
+	// their own in case the algorithm is changed
+	script := gcraScript
+	bucketKey := redisKeyPrefix + policy.Name + ":" + key
+	period := policy.Window / time.Duration(policy.Limit)
+	if policy.Algorithm == SlidingWindow {
+		script = slidingWindowScript
+		bucketKey = redisKeyPrefix + string(SlidingWindow) + ":" + policy.Name + ":" + key
+		period = policy.Window
+	}
+
+	result, err := script.Run(ctx, client, []string{bucketKey}, policy.Limit, period.Microseconds()).Int64Slice()
+	rl.breaker.Record(err, time.Now(), rl.Config.RedisRetryInterval)
+	if err != nil {
+		if !rl.degraded.Swap(true) {
+			rl.Log.FromContext(ctx).Warnw("Redis rate limiting failed, falling back to in-memory limits", "error", err)
+		}
+		return rl.Fallback.Allow(ctx, policy, key)
+	}
+	if rl.degraded.Swap(false) {
+		rl.Log.FromContext(ctx).Info("Redis rate limiting recovered")
+	}
+
+	return Decision{
+		Allowed:    result[0] == 1,
+		Limit:      policy.Limit,
+		Remaining:  int(result[1]),
+		Reset:      time.Duration(result[2]) * time.Microsecond,
+		RetryAfter: time.Duration(result[3]) * time.Microsecond,
+	}
+}
@@ -0,0 +1,143 @@
+package security
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+)
+
+func newRedisLimiter(t *testing.T, client *redis.Client) (*RedisRateLimiter, *InMemoryRateLimiter) {
+	fallback, _ := newTestLimiter(t, nil)
+	rl := &RedisRateLimiter{
+		Config:   &config.Config{Redis: client, RedisRetryInterval: 50 * time.Millisecond},
+		Log:      logger.NewNopLogger(),
+		Fallback: fallback,
+	}
+	return rl, fallback
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	policy := Policy{Name: "write", Limit: 3, Window: 3 * time.Minute}
+
+	// Two replicas share the same buckets
+	replica1, fallback := newRedisLimiter(t, client)
+	replica2, _ := newRedisLimiter(t, client)
+
+	d := replica1.Allow(ctx, policy, "client")
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 2, d.Remaining)
+	assert.Equal(t, time.Minute, d.Reset)
+
+	assert.True(t, replica2.Allow(ctx, policy, "client").Allowed)
+	assert.True(t, replica1.Allow(ctx, policy, "client").Allowed)
+
+	d = replica2.Allow(ctx, policy, "client")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 0, d.Remaining)
+	assert.InDelta(t, time.Minute, d.RetryAfter, float64(time.Second))
+
+	assert.True(t, replica1.Allow(ctx, policy, "other").Allowed)
+	assert.Empty(t, fallback.limits)
+
+	// Keys expire once the bucket would be full again
+	assert.True(t, server.Exists("ratelimit:write:client"))
+	server.FastForward(3 * time.Minute)
+	assert.False(t, server.Exists("ratelimit:write:client"))
+}
+
+func TestRedisRateLimiterSlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	rl, fallback := newRedisLimiter(t, client)
+	policy := Policy{Name: "write", Limit: 4, Window: 10 * time.Second, Algorithm: SlidingWindow}
+
+	now := time.Unix(1700000000, 0)
+	advance := func(d time.Duration) {
+		now = now.Add(d)
+		server.SetTime(now)
+		server.FastForward(d)
+	}
+	advance(0)
+
+	for i := 0; i < 4; i++ {
+		assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+	}
+	d := rl.Allow(ctx, policy, "client")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 0, d.Remaining)
+	assert.Equal(t, 10*time.Second, d.RetryAfter)
+
+	// Halfway into the next window half of the previous one still counts
+	advance(15 * time.Second)
+	assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+	assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+	d = rl.Allow(ctx, policy, "client")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 2500*time.Millisecond, d.RetryAfter)
+	assert.Equal(t, 5*time.Second, d.Reset)
+
+	advance(d.RetryAfter)
+	assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+	assert.Empty(t, fallback.limits)
+
+	// Windows older than the previous one are forgotten, and so is the key
+	assert.True(t, server.Exists("ratelimit:sliding_window:write:client"))
+	advance(time.Minute)
+	assert.False(t, server.Exists("ratelimit:sliding_window:write:client"))
+	for i := 0; i < 4; i++ {
+		assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+	}
+}
+
+func TestRedisRateLimiterFallback(t *testing.T) {
+	ctx := context.Background()
+	policy := Policy{Name: "write", Limit: 1, Window: time.Minute}
+
+	t.Run("redis not configured", func(t *testing.T) {
+		rl, fallback := newRedisLimiter(t, nil)
+
+		assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+		assert.False(t, rl.Allow(ctx, policy, "client").Allowed)
+		assert.Len(t, fallback.limits, 1)
+	})
+
+	t.Run("redis unavailable", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+		rl, fallback := newRedisLimiter(t, client)
+		server.Close()
+
+		assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+		assert.False(t, rl.Allow(ctx, policy, "client").Allowed)
+		assert.Len(t, fallback.limits, 1)
+	})
+	t.Run("redis skipped while down", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+		rl, fallback := newRedisLimiter(t, client)
+		server.Close()
+
+		assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+		require.NoError(t, server.Restart())
+
+		// Until the retry interval has passed Redis isn't tried again
+		assert.True(t, rl.Allow(ctx, policy, "other").Allowed)
+		assert.False(t, server.Exists("ratelimit:write:other"))
+		assert.Len(t, fallback.limits, 2)
+
+		time.Sleep(60 * time.Millisecond)
+		assert.True(t, rl.Allow(ctx, policy, "client").Allowed)
+		assert.True(t, server.Exists("ratelimit:write:client"))
+	})
+}
@@ -0,0 +1,16 @@
+package services
+
+import (
+	"context"
+
+	"tuhuynh.com/go-ioc-gin-example/entities"
+)
+
+// APIKeyService manages the API keys of service callers. Secrets are only
+// returned by Mint and Rotate and can't be recovered afterwards.
+type APIKeyService interface {
+	Mint(ctx context.Context, name, subject string, scopes []string) (entities.APIKey, string, error)
+	List(ctx context.Context) ([]entities.APIKey, error)
+	Rotate(ctx context.Context, id int) (entities.APIKey, string, error)
+	Revoke(ctx context.Context, id int) error
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/entities"
+	"tuhuynh.com/go-ioc-gin-example/repositories"
+	"tuhuynh.com/go-ioc-gin-example/security"
+)
+
+type APIKeyServiceImpl struct {
+	Component  struct{}                      `implements:"APIKeyService"`
+	Repository repositories.APIKeyRepository `autowired:"true" qualifier:"sql"`
+}
+
+// Mint creates a key acting as subject, which defaults to "service:<name>"
+func (s *APIKeyServiceImpl) Mint(ctx context.Context, name, subject string, scopes []string) (entities.APIKey, string, error) {
+	for _, scope := range scopes {
+		if !security.ValidPermission(scope) {
+			return entities.APIKey{}, "", apperrors.Validation("unknown scope %q", scope)
+		}
+	}
+	if subject == "" {
+		subject = "service:" + name
+	}
+
+	secret, prefix, hash, err := security.GenerateAPIKey()
+	if err != nil {
+		return entities.APIKey{}, "", err
+	}
+
+	key, err := s.Repository.Create(ctx, entities.APIKey{
+		Name:    name,
+		Prefix:  prefix,
+		Hash:    hash,
+		Subject: subject,
+		Scopes:  strings.Join(scopes, " "),
+	})
+	if err != nil {
+		return entities.APIKey{}, "", err
+	}
+	return key, secret, nil
+}
+
+func (s *APIKeyServiceImpl) List(ctx context.Context) ([]entities.APIKey, error) {
+	return s.Repository.List(ctx)
+}
+
+// Rotate replaces the secret of a key; the old secret stops working immediately
+func (s *APIKeyServiceImpl) Rotate(ctx context.Context, id int) (entities.APIKey, string, error) {
+	secret, prefix, hash, err := security.GenerateAPIKey()
+	if err != nil {
+		return entities.APIKey{}, "", err
+	}
+
+	key, err := s.Repository.Rotate(ctx, id, prefix, hash)
+	if err != nil {
+		return entities.APIKey{}, "", err
+	}
+	return key, secret, nil
+}
+
+func (s *APIKeyServiceImpl) Revoke(ctx context.Context, id int) error {
+	return s.Repository.Revoke(ctx, id, time.Now())
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
+	"tuhuynh.com/go-ioc-gin-example/repositories"
+	"tuhuynh.com/go-ioc-gin-example/security"
+)
+
+func TestAPIKeyServiceImpl(t *testing.T) {
+	ctx := context.Background()
+	repo := &repositories.APIKeyRepositoryMock{}
+	service := &APIKeyServiceImpl{Repository: repo}
+
+	key, secret, err := service.Mint(ctx, "batch", "", []string{string(security.PermissionTodosRead)})
+	assert.NoError(t, err)
+	assert.Equal(t, "service:batch", key.Subject)
+	assert.Equal(t, []string{"todos:read"}, key.ScopeList())
+	assert.Equal(t, security.HashAPIKey(secret), key.Hash)
+	assert.Equal(t, secret[:len(key.Prefix)], key.Prefix)
+
+	_, _, err = service.Mint(ctx, "batch", "", []string{"billing:read"})
+	assert.ErrorIs(t, err, apperrors.ErrValidation)
+
+	// Rotating invalidates the old secret
+	rotated, newSecret, err := service.Rotate(ctx, key.ID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, secret, newSecret)
+	assert.Equal(t, security.HashAPIKey(newSecret), rotated.Hash)
+	_, err = repo.GetByHash(ctx, security.HashAPIKey(secret))
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	// Revoking is idempotent and revoked keys can't be rotated
+	assert.NoError(t, service.Revoke(ctx, key.ID))
+	assert.NoError(t, service.Revoke(ctx, key.ID))
+	_, _, err = service.Rotate(ctx, key.ID)
+	assert.ErrorIs(t, err, apperrors.ErrConflict)
+
+	assert.ErrorIs(t, service.Revoke(ctx, 42), apperrors.ErrNotFound)
+
+	keys, err := service.List(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.True(t, keys[0].Revoked())
+}
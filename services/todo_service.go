@@ -4,12 +4,14 @@ import (
 	"context"
 
 	"tuhuynh.com/go-ioc-gin-example/entities"
+	"tuhuynh.com/go-ioc-gin-example/repositories"
 )
 
 type TodoService interface {
-	List(ctx context.Context) ([]entities.Todo, error)
+	List(ctx context.Context, query repositories.TodoListQuery) (repositories.TodoPage, error)
 	Create(ctx context.Context, todo entities.Todo) error
 	Get(ctx context.Context, id int) (entities.Todo, error)
-	Update(ctx context.Context, todo entities.Todo) error
-	Delete(ctx context.Context, id int) error
+	Update(ctx context.Context, todo entities.Todo) (entities.Todo, error)
+	Patch(ctx context.Context, id int, patch repositories.TodoPatch) (entities.Todo, error)
+	Delete(ctx context.Context, id int, version int) error
 }
@@ -2,98 +2,196 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
 
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
 	"tuhuynh.com/go-ioc-gin-example/cache"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/database"
 	"tuhuynh.com/go-ioc-gin-example/entities"
 	"tuhuynh.com/go-ioc-gin-example/repositories"
+	"tuhuynh.com/go-ioc-gin-example/security"
 )
 
+const listCachePrefix = "todos:list:"
+
 type TodoServiceImpl struct {
 	Component  struct{}                        `implements:"TodoService"`
+	Config     *config.Config                  `autowired:"true"`
 	Repository repositories.TodoCrudRepository `autowired:"true" qualifier:"sql"`
 	Cache      cache.Cache                     `autowired:"true" qualifier:"redis"`
+
+	lists *cache.Loader[repositories.TodoPage]
+	items *cache.Loader[entities.Todo]
 }
 
-func (s *TodoServiceImpl) List(ctx context.Context) ([]entities.Todo, error) {
-	// Try to get from cache first
-	if cached, err := s.Cache.Get(ctx, "todos:list"); err == nil && cached != nil {
-		var todos []entities.Todo
-		if err := json.Unmarshal([]byte(cached.(string)), &todos); err == nil {
-			return todos, nil
-		}
+// PostConstruct sets up the cache loaders. What they cache is read from the
+// primary, as a replica may not have the latest writes yet.
+func (s *TodoServiceImpl) PostConstruct() {
+	s.lists = &cache.Loader[repositories.TodoPage]{
+		Cache:       s.Cache,
+		TTL:         s.Config.CacheListTTL,
+		NegativeTTL: s.Config.CacheNegativeTTL,
+		Jitter:      s.Config.CacheTTLJitter,
+		Beta:        s.Config.CacheEarlyRefreshBeta,
+		Timeout:     s.Config.CacheLoadTimeout,
+		Fill:        database.ReadFromPrimary,
+	}
+	s.items = &cache.Loader[entities.Todo]{
+		Cache:       s.Cache,
+		TTL:         s.Config.CacheItemTTL,
+		NegativeTTL: s.Config.CacheNegativeTTL,
+		Jitter:      s.Config.CacheTTLJitter,
+		Beta:        s.Config.CacheEarlyRefreshBeta,
+		Timeout:     s.Config.CacheLoadTimeout,
+		Fill:        database.ReadFromPrimary,
 	}
+}
 
-	// If not in cache, get from repository
-	todos, err := s.Repository.List(ctx)
+// ownerOf returns the subject of the authenticated caller, which every
+// operation is scoped to
+func ownerOf(ctx context.Context) (string, error) {
+	principal, ok := security.PrincipalFromContext(ctx)
+	if !ok || principal.Subject == "" {
+		return "", apperrors.Unauthenticated("authentication required")
+	}
+	return principal.Subject, nil
+}
+
+func (s *TodoServiceImpl) List(ctx context.Context, query repositories.TodoListQuery) (repositories.TodoPage, error) {
+	owner, err := ownerOf(ctx)
 	if err != nil {
-		return nil, err
+		return repositories.TodoPage{}, err
+	}
+	query.OwnerID = owner
+
+	query, err = query.Normalize()
+	if err != nil {
+		return repositories.TodoPage{}, err
+	}
+
+	if database.InTx(ctx) {
+		return s.Repository.List(ctx, query)
 	}
+	return s.lists.Get(ctx, listCacheKey(query), func(ctx context.Context) (repositories.TodoPage, error) {
+		return s.Repository.List(ctx, query)
+	})
+}
 
-	// Cache the results
-	if todosJson, err := json.Marshal(todos); err == nil {
-		s.Cache.Set(ctx, "todos:list", string(todosJson))
+// listCacheKey builds the cache key for a list query. Every list key of an
+// owner shares listOwnerPrefix so a write can drop all of the owner's cached pages at once.
+func listCacheKey(query repositories.TodoListQuery) string {
+	completed := ""
+	if query.Completed != nil {
+		completed = strconv.FormatBool(*query.Completed)
 	}
 
-	return todos, nil
+	return fmt.Sprintf("%s%d:%s:%s:%s:%s",
+		listOwnerPrefix(query.OwnerID), query.Limit, query.Sort, completed, url.QueryEscape(query.Search), query.Cursor)
+}
+
+// listOwnerPrefix is escaped so no owner's prefix is a prefix of another's
+func listOwnerPrefix(owner string) string {
+	return listCachePrefix + url.QueryEscape(owner) + ":"
+}
+
+func itemCacheKey(owner string, id int) string {
+	return fmt.Sprintf("todos:%s:%d", url.QueryEscape(owner), id)
+}
+
+// invalidate drops every cached list page of the owner and the cached todos
+// with the given ids, once the transaction of ctx, if any, has committed. A
+// transaction writing many todos of the owner drops them all at once.
+func (s *TodoServiceImpl) invalidate(ctx context.Context, owner string, ids ...int) {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, itemCacheKey(owner, id))
+	}
+
+	database.AfterCommitBatched(ctx, listOwnerPrefix(owner), keys, func(keys []string) {
+		s.items.Invalidate(ctx, keys...)
+		s.lists.InvalidatePrefix(ctx, listOwnerPrefix(owner))
+	})
 }
 
 func (s *TodoServiceImpl) Create(ctx context.Context, todo entities.Todo) error {
-	err := s.Repository.Create(ctx, todo)
+	owner, err := ownerOf(ctx)
+	if err != nil {
+		return err
+	}
+	todo.OwnerID = owner
+
+	created, err := s.Repository.Create(ctx, todo)
 	if err != nil {
 		return err
 	}
 
-	// Invalidate list cache
-	s.Cache.Set(ctx, "todos:list", nil)
+	// Invalidate caches, including a not found result cached for the new id
+	s.invalidate(ctx, owner, created.ID)
 	return nil
 }
 
 func (s *TodoServiceImpl) Get(ctx context.Context, id int) (entities.Todo, error) {
-	// Try to get from cache first
-	cacheKey := fmt.Sprintf("todos:%d", id)
-	if cached, err := s.Cache.Get(ctx, cacheKey); err == nil && cached != nil {
-		var todo entities.Todo
-		if err := json.Unmarshal([]byte(cached.(string)), &todo); err == nil {
-			return todo, nil
-		}
+	owner, err := ownerOf(ctx)
+	if err != nil {
+		return entities.Todo{}, err
 	}
 
-	// If not in cache, get from repository
-	todo, err := s.Repository.Get(ctx, id)
+	if database.InTx(ctx) {
+		return s.Repository.Get(ctx, owner, id)
+	}
+	return s.items.Get(ctx, itemCacheKey(owner, id), func(ctx context.Context) (entities.Todo, error) {
+		return s.Repository.Get(ctx, owner, id)
+	})
+}
+
+func (s *TodoServiceImpl) Update(ctx context.Context, todo entities.Todo) (entities.Todo, error) {
+	owner, err := ownerOf(ctx)
 	if err != nil {
-		return todo, err
+		return entities.Todo{}, err
 	}
+	todo.OwnerID = owner
 
-	// Cache the result
-	if todoJson, err := json.Marshal(todo); err == nil {
-		s.Cache.Set(ctx, cacheKey, string(todoJson))
+	updated, err := s.Repository.Update(ctx, todo)
+	if err != nil {
+		return updated, err
 	}
 
-	return todo, nil
+	// Invalidate caches
+	s.invalidate(ctx, owner, todo.ID)
+	return updated, nil
 }
 
-func (s *TodoServiceImpl) Update(ctx context.Context, todo entities.Todo) error {
-	err := s.Repository.Update(ctx, todo)
+func (s *TodoServiceImpl) Patch(ctx context.Context, id int, patch repositories.TodoPatch) (entities.Todo, error) {
+	owner, err := ownerOf(ctx)
 	if err != nil {
-		return err
+		return entities.Todo{}, err
+	}
+
+	todo, err := s.Repository.Patch(ctx, owner, id, patch)
+	if err != nil {
+		return todo, err
 	}
 
 	// Invalidate caches
-	s.Cache.Set(ctx, fmt.Sprintf("todos:%d", todo.ID), nil)
-	s.Cache.Set(ctx, "todos:list", nil)
-	return nil
+	s.invalidate(ctx, owner, id)
+	return todo, nil
 }
 
-func (s *TodoServiceImpl) Delete(ctx context.Context, id int) error {
-	err := s.Repository.Delete(ctx, id)
+func (s *TodoServiceImpl) Delete(ctx context.Context, id int, version int) error {
+	owner, err := ownerOf(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repository.Delete(ctx, owner, id, version)
 	if err != nil {
 		return err
 	}
 
 	// Invalidate caches
-	s.Cache.Set(ctx, fmt.Sprintf("todos:%d", id), nil)
-	s.Cache.Set(ctx, "todos:list", nil)
+	s.invalidate(ctx, owner, id)
 	return nil
 }
@@ -2,28 +2,55 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/glebarez/sqlite"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"tuhuynh.com/go-ioc-gin-example/apperrors"
 	"tuhuynh.com/go-ioc-gin-example/cache"
+	"tuhuynh.com/go-ioc-gin-example/config"
+	"tuhuynh.com/go-ioc-gin-example/database"
 	"tuhuynh.com/go-ioc-gin-example/entities"
+	"tuhuynh.com/go-ioc-gin-example/logger"
+	"tuhuynh.com/go-ioc-gin-example/migrations"
 	"tuhuynh.com/go-ioc-gin-example/repositories"
+	"tuhuynh.com/go-ioc-gin-example/security"
 )
 
+const testOwner = "alice"
+
+// userContext returns a context authenticated as owner
+func userContext(owner string) context.Context {
+	return security.WithPrincipal(context.Background(), security.Principal{Subject: owner, Method: "jwt"})
+}
+
+// owned assigns the todo to testOwner
+func owned(todo entities.Todo) entities.Todo {
+	todo.OwnerID = testOwner
+	return todo
+}
+
 func setupTestService() (*TodoServiceImpl, *repositories.TodoCrudRepositoryMock, *cache.RedisMock) {
 	mockRepo := &repositories.TodoCrudRepositoryMock{}
 	mockCache := &cache.RedisMock{}
 	service := &TodoServiceImpl{
+		Config:     &config.Config{CacheListTTL: time.Minute, CacheItemTTL: time.Minute, CacheNegativeTTL: time.Minute},
 		Repository: mockRepo,
 		Cache:      mockCache,
 	}
+	service.PostConstruct()
 	return service, mockRepo, mockCache
 }
 
 func TestTodoServiceImpl_Create(t *testing.T) {
 	service, repo, cache := setupTestService()
-	ctx := context.Background()
+	ctx := userContext(testOwner)
 
 	todo := entities.Todo{
 		Title:     "Test Todo",
@@ -34,8 +61,9 @@ func TestTodoServiceImpl_Create(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Verify todo was created in repository
-	todos, err := repo.List(ctx)
+	page, err := repo.List(ctx, repositories.TodoListQuery{OwnerID: testOwner})
 	assert.NoError(t, err)
+	todos := page.Todos
 	assert.Len(t, todos, 1)
 	assert.Equal(t, todo.Title, todos[0].Title)
 
@@ -47,19 +75,20 @@ func TestTodoServiceImpl_Create(t *testing.T) {
 
 func TestTodoServiceImpl_Get(t *testing.T) {
 	service, repo, _ := setupTestService()
-	ctx := context.Background()
+	ctx := userContext(testOwner)
 
 	// Create a todo first
 	todo := entities.Todo{
 		Title:     "Test Todo",
 		Completed: false,
 	}
-	err := repo.Create(ctx, todo)
+	_, err := repo.Create(ctx, owned(todo))
 	assert.NoError(t, err)
 
 	// Get the created todo
-	todos, err := repo.List(ctx)
+	page, err := repo.List(ctx, repositories.TodoListQuery{OwnerID: testOwner})
 	assert.NoError(t, err)
+	todos := page.Todos
 	createdTodo := todos[0]
 
 	// Test getting the todo
@@ -71,7 +100,7 @@ func TestTodoServiceImpl_Get(t *testing.T) {
 
 func TestTodoServiceImpl_List(t *testing.T) {
 	service, repo, _ := setupTestService()
-	ctx := context.Background()
+	ctx := userContext(testOwner)
 
 	// Create some test todos
 	todos := []entities.Todo{
@@ -80,38 +109,130 @@ func TestTodoServiceImpl_List(t *testing.T) {
 	}
 
 	for _, todo := range todos {
-		err := repo.Create(ctx, todo)
+		_, err := repo.Create(ctx, owned(todo))
 		assert.NoError(t, err)
 	}
 
 	// Test listing todos
-	fetchedTodos, err := service.List(ctx)
+	page, err := service.List(ctx, repositories.TodoListQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, page.Todos, 2)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestTodoServiceImpl_ListPagination(t *testing.T) {
+	service, repo, _ := setupTestService()
+	ctx := userContext(testOwner)
+
+	for i := 1; i <= 5; i++ {
+		_, err := repo.Create(ctx, owned(entities.Todo{Title: fmt.Sprintf("Todo %d", i), Completed: i%2 == 0}))
+		assert.NoError(t, err)
+	}
+
+	// Walk every page and collect the ids in order
+	var ids []int
+	query := repositories.TodoListQuery{Limit: 2}
+	for {
+		page, err := service.List(ctx, query)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(page.Todos), 2)
+		for _, todo := range page.Todos {
+			ids = append(ids, todo.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, ids)
+
+	// Filter by completion and search, newest first
+	completed := true
+	page, err := service.List(ctx, repositories.TodoListQuery{Completed: &completed, Sort: repositories.SortCreatedAtDesc})
+	assert.NoError(t, err)
+	assert.Len(t, page.Todos, 2)
+	assert.Equal(t, 4, page.Todos[0].ID)
+	assert.Equal(t, 2, page.Todos[1].ID)
+
+	page, err = service.List(ctx, repositories.TodoListQuery{Search: "todo 3"})
+	assert.NoError(t, err)
+	assert.Len(t, page.Todos, 1)
+	assert.Equal(t, 3, page.Todos[0].ID)
+
+	// A cursor issued for one sort order is rejected for another
+	first, err := service.List(ctx, repositories.TodoListQuery{Limit: 1})
+	assert.NoError(t, err)
+	_, err = service.List(ctx, repositories.TodoListQuery{Limit: 1, Cursor: first.NextCursor, Sort: repositories.SortUpdatedAtDesc})
+	assert.ErrorIs(t, err, repositories.ErrInvalidCursor)
+}
+
+func TestTodoServiceImpl_ListCacheInvalidation(t *testing.T) {
+	service, repo, _ := setupTestService()
+	ctx := userContext(testOwner)
+
+	_, err := repo.Create(ctx, owned(entities.Todo{Title: "Todo 1"}))
+	assert.NoError(t, err)
+
+	page, err := service.List(ctx, repositories.TodoListQuery{})
 	assert.NoError(t, err)
-	assert.Len(t, fetchedTodos, 2)
+	assert.Len(t, page.Todos, 1)
+
+	// A write through the service must not leave a stale cached page behind
+	err = service.Create(ctx, entities.Todo{Title: "Todo 2"})
+	assert.NoError(t, err)
+
+	page, err = service.List(ctx, repositories.TodoListQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, page.Todos, 2)
+}
+
+func TestTodoServiceImpl_CacheTTL(t *testing.T) {
+	service, repo, cache := setupTestService()
+	service.Config.CacheItemTTL = 10 * time.Millisecond
+	service.PostConstruct()
+	ctx := userContext(testOwner)
+
+	_, err := repo.Create(ctx, owned(entities.Todo{Title: "Todo 1"}))
+	assert.NoError(t, err)
+
+	_, err = service.Get(ctx, 1)
+	assert.NoError(t, err)
+
+	cachedItem, err := cache.Get(ctx, "todos:alice:1")
+	assert.NoError(t, err)
+	assert.NotNil(t, cachedItem)
+
+	time.Sleep(20 * time.Millisecond)
+
+	cachedItem, err = cache.Get(ctx, "todos:alice:1")
+	assert.NoError(t, err)
+	assert.Nil(t, cachedItem)
 }
 
 func TestTodoServiceImpl_Update(t *testing.T) {
 	service, repo, cache := setupTestService()
-	ctx := context.Background()
+	ctx := userContext(testOwner)
 
 	// Create a todo first
 	todo := entities.Todo{
 		Title:     "Test Todo",
 		Completed: false,
 	}
-	err := repo.Create(ctx, todo)
+	_, err := repo.Create(ctx, owned(todo))
 	assert.NoError(t, err)
 
-	todos, err := repo.List(ctx)
+	page, err := repo.List(ctx, repositories.TodoListQuery{OwnerID: testOwner})
 	assert.NoError(t, err)
+	todos := page.Todos
 	createdTodo := todos[0]
 
 	// Update the todo
 	createdTodo.Title = "Updated Title"
 	createdTodo.Completed = true
 
-	err = service.Update(ctx, createdTodo)
+	updated, err := service.Update(ctx, createdTodo)
 	assert.NoError(t, err)
+	assert.Equal(t, createdTodo.Version+1, updated.Version)
 
 	// Verify update
 	updatedTodo, err := service.Get(ctx, createdTodo.ID)
@@ -127,33 +248,384 @@ func TestTodoServiceImpl_Update(t *testing.T) {
 
 func TestTodoServiceImpl_Delete(t *testing.T) {
 	service, repo, cache := setupTestService()
-	ctx := context.Background()
+	ctx := userContext(testOwner)
 
 	// Create a todo first
 	todo := entities.Todo{
 		Title: "Test Todo",
 	}
-	err := repo.Create(ctx, todo)
+	_, err := repo.Create(ctx, owned(todo))
 	assert.NoError(t, err)
 
-	todos, err := repo.List(ctx)
+	page, err := repo.List(ctx, repositories.TodoListQuery{OwnerID: testOwner})
 	assert.NoError(t, err)
+	todos := page.Todos
 	createdTodo := todos[0]
 
-	// Delete the todo
-	err = service.Delete(ctx, createdTodo.ID)
+	// Warm the item cache so the delete has something to invalidate
+	_, err = service.Get(ctx, createdTodo.ID)
 	assert.NoError(t, err)
 
-	// Verify deletion
-	_, err = service.Get(ctx, createdTodo.ID)
-	assert.Error(t, err)
+	// Delete the todo
+	err = service.Delete(ctx, createdTodo.ID, 0)
+	assert.NoError(t, err)
 
 	// Verify caches were invalidated
 	cachedList, err := cache.Get(ctx, "todos:list")
 	assert.NoError(t, err)
 	assert.Nil(t, cachedList)
 
-	cachedItem, err := cache.Get(ctx, fmt.Sprintf("todos:%d", createdTodo.ID))
+	cachedItem, err := cache.Get(ctx, fmt.Sprintf("todos:alice:%d", createdTodo.ID))
 	assert.NoError(t, err)
 	assert.Nil(t, cachedItem)
+
+	// Verify deletion, which is then cached as not found
+	_, err = service.Get(ctx, createdTodo.ID)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	_, err = service.Get(ctx, createdTodo.ID)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	// Deleting again reports not found
+	err = service.Delete(ctx, createdTodo.ID, 0)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+}
+
+func TestTodoServiceImpl_Patch(t *testing.T) {
+	service, repo, cache := setupTestService()
+	ctx := userContext(testOwner)
+
+	_, err := repo.Create(ctx, owned(entities.Todo{Title: "Test Todo", Description: "Keep me"}))
+	assert.NoError(t, err)
+
+	// Warm the item cache so the patch has something to invalidate
+	_, err = service.Get(ctx, 1)
+	assert.NoError(t, err)
+
+	completed := true
+	patched, err := service.Patch(ctx, 1, repositories.TodoPatch{Completed: &completed})
+	assert.NoError(t, err)
+	assert.True(t, patched.Completed)
+	assert.Equal(t, "Test Todo", patched.Title)
+	assert.Equal(t, "Keep me", patched.Description)
+
+	cachedItem, err := cache.Get(ctx, "todos:alice:1")
+	assert.NoError(t, err)
+	assert.Nil(t, cachedItem)
+
+	_, err = service.Patch(ctx, 999, repositories.TodoPatch{Completed: &completed})
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	_, err = service.Update(ctx, entities.Todo{ID: 999, Title: "Missing"})
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+}
+
+func TestTodoServiceImpl_OptimisticConcurrency(t *testing.T) {
+	service, repo, _ := setupTestService()
+	ctx := userContext(testOwner)
+
+	_, err := repo.Create(ctx, owned(entities.Todo{Title: "Test Todo"}))
+	assert.NoError(t, err)
+
+	// Two clients read version 1
+	first, err := service.Get(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, first.Version)
+	second := first
+
+	first.Title = "First writer"
+	updated, err := service.Update(ctx, first)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	// The second writer is still on version 1 and must not overwrite the first
+	second.Title = "Second writer"
+	_, err = service.Update(ctx, second)
+	assert.ErrorIs(t, err, apperrors.ErrPreconditionFailed)
+
+	title := "Second writer"
+	_, err = service.Patch(ctx, 1, repositories.TodoPatch{Title: &title, Version: 1})
+	assert.ErrorIs(t, err, apperrors.ErrPreconditionFailed)
+
+	err = service.Delete(ctx, 1, 1)
+	assert.ErrorIs(t, err, apperrors.ErrPreconditionFailed)
+
+	current, err := service.Get(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "First writer", current.Title)
+
+	err = service.Delete(ctx, 1, current.Version)
+	assert.NoError(t, err)
+}
+
+func TestTodoServiceImpl_Ownership(t *testing.T) {
+	service, _, _ := setupTestService()
+	alice := userContext("alice")
+	bob := userContext("bob")
+
+	err := service.Create(alice, entities.Todo{Title: "Alice's todo"})
+	assert.NoError(t, err)
+
+	// Warm alice's caches so bob can't be served from them
+	page, err := service.List(alice, repositories.TodoListQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, page.Todos, 1)
+	assert.Equal(t, "alice", page.Todos[0].OwnerID)
+	_, err = service.Get(alice, 1)
+	assert.NoError(t, err)
+
+	page, err = service.List(bob, repositories.TodoListQuery{})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Todos)
+
+	_, err = service.Get(bob, 1)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	_, err = service.Update(bob, entities.Todo{ID: 1, Title: "Stolen"})
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	title := "Stolen"
+	_, err = service.Patch(bob, 1, repositories.TodoPatch{Title: &title})
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	err = service.Delete(bob, 1, 0)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	todo, err := service.Get(alice, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice's todo", todo.Title)
+
+	// Without a caller nothing is accessible
+	_, err = service.List(context.Background(), repositories.TodoListQuery{})
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+	err = service.Create(context.Background(), entities.Todo{Title: "Anonymous"})
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+}
+
+func TestTodoServiceImpl_InvalidatesAfterCommit(t *testing.T) {
+	service, repo, cache := setupTestService()
+	txManager := &database.TxManagerMock{}
+	ctx := userContext(testOwner)
+
+	_, err := repo.Create(ctx, owned(entities.Todo{Title: "Test Todo"}))
+	assert.NoError(t, err)
+	_, err = service.Get(ctx, 1)
+	assert.NoError(t, err)
+
+	// The cached todo stays until the unit of work commits
+	completed := true
+	err = txManager.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := service.Patch(ctx, 1, repositories.TodoPatch{Completed: &completed})
+		assert.NoError(t, err)
+
+		cachedItem, err := cache.Get(ctx, "todos:alice:1")
+		assert.NoError(t, err)
+		assert.NotNil(t, cachedItem)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	cachedItem, err := cache.Get(ctx, "todos:alice:1")
+	assert.NoError(t, err)
+	assert.Nil(t, cachedItem)
+}
+
+func TestTodoServiceImpl_RollsBackWithMocks(t *testing.T) {
+	service, repo, _ := setupTestService()
+	txManager := &database.TxManagerMock{}
+	ctx := userContext(testOwner)
+
+	_, err := repo.Create(ctx, owned(entities.Todo{Title: "Kept"}))
+	require.NoError(t, err)
+
+	err = txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := service.Create(ctx, entities.Todo{Title: "Created"}); err != nil {
+			return err
+		}
+		completed := true
+		if _, err := service.Patch(ctx, 1, repositories.TodoPatch{Completed: &completed}); err != nil {
+			return err
+		}
+		return errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+
+	// The failed unit of work leaves nothing behind
+	page, err := service.List(ctx, repositories.TodoListQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Todos, 1)
+	assert.Equal(t, "Kept", page.Todos[0].Title)
+	assert.False(t, page.Todos[0].Completed)
+	assert.Equal(t, 1, page.Todos[0].Version)
+}
+
+func TestTodoServiceImpl_NegativeCaching(t *testing.T) {
+	service, repo, _ := setupTestService()
+	ctx := userContext(testOwner)
+
+	_, err := service.Get(ctx, 1)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	// Found missing by the repository, yet still reported from the cache
+	_, err = repo.Create(ctx, owned(entities.Todo{Title: "Test Todo"}))
+	assert.NoError(t, err)
+	_, err = service.Get(ctx, 1)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	assert.EqualError(t, err, "todo 1 not found")
+
+	// Creating through the service drops the cached result for the new id
+	err = service.Create(ctx, entities.Todo{Title: "Second Todo"})
+	assert.NoError(t, err)
+	_, err = service.Get(ctx, 3)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	err = service.Create(ctx, entities.Todo{Title: "Third Todo"})
+	assert.NoError(t, err)
+	todo, err := service.Get(ctx, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "Third Todo", todo.Title)
+}
+
+// newMigratedDB returns an in-memory SQLite database with the schema migrated
+func newMigratedDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// Every connection to :memory: is a new database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	runner := &migrations.Runner{Log: logger.NewTestLogger(), Config: &config.Config{DB: db}}
+	runner.PostConstruct()
+	require.NoError(t, runner.Up(context.Background()))
+	return db
+}
+
+func TestTodoServiceImpl_LaggingReplica(t *testing.T) {
+	cfg := &config.Config{
+		DB: newMigratedDB(t),
+		// The replica never receives the writes, as if it lagged behind
+		Replicas:               []*gorm.DB{newMigratedDB(t)},
+		DBReplicaCheckInterval: time.Hour,
+		DBReadYourWritesWindow: time.Minute,
+		HealthCheckTimeout:     time.Second,
+		CacheListTTL:           time.Minute,
+		CacheItemTTL:           time.Minute,
+		CacheNegativeTTL:       time.Minute,
+	}
+	resolver := &database.Resolver{Config: cfg, Log: logger.NewTestLogger()}
+	resolver.PostConstruct()
+	t.Cleanup(resolver.PreDestroy)
+
+	service := &TodoServiceImpl{
+		Config:     cfg,
+		Repository: &repositories.TodoCrudRepositorySql{Resolver: resolver},
+		Cache:      &cache.RedisMock{},
+	}
+	service.PostConstruct()
+
+	// Every call is a new request, so read-your-writes doesn't apply
+	request := func() context.Context { return database.WithSession(userContext(testOwner)) }
+	require.NoError(t, service.Create(request(), entities.Todo{Title: "Buy milk"}))
+
+	// Misses are filled from the primary rather than the lagging replica,
+	// which would cache an empty page and a not found todo
+	for i := 0; i < 2; i++ {
+		page, err := service.List(request(), repositories.TodoListQuery{})
+		require.NoError(t, err)
+		require.Len(t, page.Todos, 1)
+
+		todo, err := service.Get(request(), page.Todos[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Buy milk", todo.Title)
+	}
+}
+
+// countingCache counts prefix deletes, which scan every key in Redis
+type countingCache struct {
+	cache.RedisMock
+	prefixDeletes atomic.Int32
+}
+
+func (c *countingCache) DeleteByPrefix(ctx context.Context, prefix string) error {
+	c.prefixDeletes.Add(1)
+	return c.RedisMock.DeleteByPrefix(ctx, prefix)
+}
+
+func TestTodoServiceImpl_InvalidatesOncePerTx(t *testing.T) {
+	service, _, _ := setupTestService()
+	counting := &countingCache{}
+	service.Cache = counting
+	service.PostConstruct()
+	txManager := &database.TxManagerMock{}
+	ctx := userContext(testOwner)
+
+	// Cache a not found result for the todo about to be created last
+	_, err := service.Get(ctx, 3)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	err = txManager.WithinTx(ctx, func(ctx context.Context) error {
+		for i := 0; i < 3; i++ {
+			if err := service.Create(ctx, entities.Todo{Title: fmt.Sprintf("Todo %d", i)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, int32(1), counting.prefixDeletes.Load())
+	todo, err := service.Get(ctx, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "Todo 2", todo.Title)
+}
+
+func TestTodoServiceImpl_ReadsWithinTx(t *testing.T) {
+	cfg := &config.Config{
+		DB:                     newMigratedDB(t),
+		DBReplicaCheckInterval: time.Hour,
+		HealthCheckTimeout:     time.Second,
+		CacheListTTL:           time.Minute,
+		CacheItemTTL:           time.Minute,
+		CacheNegativeTTL:       time.Minute,
+		CacheLoadTimeout:       time.Second,
+	}
+	resolver := &database.Resolver{Config: cfg, Log: logger.NewTestLogger()}
+	resolver.PostConstruct()
+	t.Cleanup(resolver.PreDestroy)
+
+	c := &cache.RedisMock{}
+	service := &TodoServiceImpl{
+		Config:     cfg,
+		Repository: &repositories.TodoCrudRepositorySql{Resolver: resolver},
+		Cache:      c,
+	}
+	service.PostConstruct()
+	txManager := &database.TxManagerSql{Resolver: resolver}
+	ctx := userContext(testOwner)
+
+	// Reads see the transaction's own writes, and with a single connection
+	// they would wait for it to finish if they went anywhere else
+	err := txManager.WithinTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, service.Create(ctx, entities.Todo{Title: "Buy milk"}))
+
+		page, err := service.List(ctx, repositories.TodoListQuery{})
+		require.NoError(t, err)
+		require.Len(t, page.Todos, 1)
+
+		todo, err := service.Get(ctx, page.Todos[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Buy milk", todo.Title)
+
+		// Nor is what they read cached before the transaction commits
+		cachedItem, err := c.Get(ctx, itemCacheKey(testOwner, todo.ID))
+		assert.NoError(t, err)
+		assert.Nil(t, cachedItem)
+		query, err := repositories.TodoListQuery{OwnerID: testOwner}.Normalize()
+		require.NoError(t, err)
+		cachedPage, err := c.Get(ctx, listCacheKey(query))
+		assert.NoError(t, err)
+		assert.Nil(t, cachedPage)
+		return nil
+	})
+	require.NoError(t, err)
 }
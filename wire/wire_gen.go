@@ -8,7 +8,9 @@ import (
     "tuhuynh.com/go-ioc-gin-example/config"
     "tuhuynh.com/go-ioc-gin-example/controllers"
     "tuhuynh.com/go-ioc-gin-example/core"
+    "tuhuynh.com/go-ioc-gin-example/database"
     "tuhuynh.com/go-ioc-gin-example/logger"
+    "tuhuynh.com/go-ioc-gin-example/middleware"
     "tuhuynh.com/go-ioc-gin-example/migrations"
     "tuhuynh.com/go-ioc-gin-example/repositories"
     "tuhuynh.com/go-ioc-gin-example/security"
@@ -19,15 +21,29 @@ type Container struct {
     LRUCache *cache.LRUCache
     RedisMock *cache.RedisMock
     Config *config.Config
-    HealthCheck *core.HealthCheck
+    ZapLogger *logger.ZapLogger
+    Resolver *database.Resolver
+    TxManagerSql *database.TxManagerSql
+    TxManagerMock *database.TxManagerMock
     TodoCrudRepositoryMock *repositories.TodoCrudRepositoryMock
-    RateLimiter *security.RateLimiter
+    APIKeyRepositoryMock *repositories.APIKeyRepositoryMock
+    InMemoryRateLimiter *security.InMemoryRateLimiter
     RedisCache *cache.RedisCache
     TodoCrudRepositorySql *repositories.TodoCrudRepositorySql
     TodoServiceImpl *services.TodoServiceImpl
     TodoController *controllers.TodoController
-    ZapLogger *logger.ZapLogger
+    APIKeyRepositorySql *repositories.APIKeyRepositorySql
+    APIKeyServiceImpl *services.APIKeyServiceImpl
+    APIKeyController *controllers.APIKeyController
+    RedisRateLimiter *security.RedisRateLimiter
+    JWTAuthenticator *security.JWTAuthenticator
+    APIKeyAuthenticator *security.APIKeyAuthenticator
+    Authenticator *security.Authenticator
+    RequestLogger *middleware.RequestLogger
+    Recovery *middleware.Recovery
+    ErrorHandler *middleware.ErrorHandler
     Runner *migrations.Runner
+    HealthCheck *core.HealthCheck
     Application *core.Application
 }
 
@@ -40,48 +56,128 @@ func Initialize() (*Container, func()) {
     
     container.Config = config.NewConfig()
     
-    container.HealthCheck = &core.HealthCheck{}
+    container.ZapLogger = logger.NewZapLogger(container.Config)
+    
+    container.Resolver = &database.Resolver{
+        Config: container.Config,
+        Log: container.ZapLogger,
+    }
+    container.Resolver.PostConstruct()
+    
+    container.TxManagerSql = &database.TxManagerSql{
+        Resolver: container.Resolver,
+    }
+    
+    container.TxManagerMock = &database.TxManagerMock{}
     
     container.TodoCrudRepositoryMock = &repositories.TodoCrudRepositoryMock{}
     
-    container.RateLimiter = &security.RateLimiter{}
-    container.RateLimiter.PostConstruct()
+    container.APIKeyRepositoryMock = &repositories.APIKeyRepositoryMock{}
+    
+    container.InMemoryRateLimiter = &security.InMemoryRateLimiter{
+        Config: container.Config,
+    }
+    container.InMemoryRateLimiter.PostConstruct()
     
     container.RedisCache = &cache.RedisCache{
         Config: container.Config,
     }
     
     container.TodoCrudRepositorySql = &repositories.TodoCrudRepositorySql{
-        Config: container.Config,
+        Resolver: container.Resolver,
     }
     
     container.TodoServiceImpl = &services.TodoServiceImpl{
+        Config: container.Config,
         Repository: container.TodoCrudRepositorySql,
         Cache: container.RedisCache,
     }
+    container.TodoServiceImpl.PostConstruct()
     
     container.TodoController = &controllers.TodoController{
         Service: container.TodoServiceImpl,
-        RateLimiter: container.RateLimiter,
     }
     
-    container.ZapLogger = logger.NewZapLogger(container.Config)
+    container.APIKeyRepositorySql = &repositories.APIKeyRepositorySql{
+        Config: container.Config,
+    }
+    
+    container.APIKeyServiceImpl = &services.APIKeyServiceImpl{
+        Repository: container.APIKeyRepositorySql,
+    }
+    
+    container.APIKeyController = &controllers.APIKeyController{
+        Service: container.APIKeyServiceImpl,
+    }
+    
+    container.RedisRateLimiter = &security.RedisRateLimiter{
+        Config: container.Config,
+        Log: container.ZapLogger,
+        Fallback: container.InMemoryRateLimiter,
+    }
+    
+    container.JWTAuthenticator = &security.JWTAuthenticator{
+        Config: container.Config,
+        Log: container.ZapLogger,
+    }
+    container.JWTAuthenticator.PostConstruct()
+    
+    container.APIKeyAuthenticator = &security.APIKeyAuthenticator{
+        Repository: container.APIKeyRepositorySql,
+        Log: container.ZapLogger,
+    }
+    container.APIKeyAuthenticator.PostConstruct()
+    
+    container.Authenticator = &security.Authenticator{
+        JWT: container.JWTAuthenticator,
+        APIKeys: container.APIKeyAuthenticator,
+        Log: container.ZapLogger,
+    }
+    
+    container.RequestLogger = &middleware.RequestLogger{
+        Log: container.ZapLogger,
+    }
+    
+    container.Recovery = &middleware.Recovery{
+        Log: container.ZapLogger,
+    }
+    
+    container.ErrorHandler = &middleware.ErrorHandler{
+        Log: container.ZapLogger,
+    }
     
     container.Runner = &migrations.Runner{
         Log: container.ZapLogger,
         Config: container.Config,
     }
+    container.Runner.PostConstruct()
+    
+    container.HealthCheck = &core.HealthCheck{
+        Config: container.Config,
+        MigrationRunner: container.Runner,
+        Resolver: container.Resolver,
+    }
+    container.HealthCheck.PostConstruct()
     
     container.Application = &core.Application{
         Config: container.Config,
         Log: container.ZapLogger,
         HealthCheck: container.HealthCheck,
+        RequestLogger: container.RequestLogger,
+        Recovery: container.Recovery,
+        ErrorHandler: container.ErrorHandler,
+        RateLimiter: container.RedisRateLimiter,
+        Authenticator: container.Authenticator,
         TodoController: container.TodoController,
+        APIKeyController: container.APIKeyController,
         MigrationRunner: container.Runner,
+        Resolver: container.Resolver,
     }
 
     cleanup := func() {
         container.ZapLogger.PreDestroy()
+        container.InMemoryRateLimiter.PreDestroy()
+        container.Resolver.PreDestroy()
         container.Config.PreDestroy()
     }
 